@@ -0,0 +1,493 @@
+// Package cassette records outgoing HTTP interactions into golden files and
+// replays them in tests, so that tests never reach real third parties.
+//
+// Usage:
+//
+//	rec := cassette.New(t, "partner_login")
+//	cl := httpc.New(httpc.SetTransport(rec))
+//	env := httpie.NewEnvWithClient(rec.Client())
+//
+// By default interactions are replayed from testdata/cassettes/<name>.json.
+// Run the test with AGL_CASSETTE_RECORD=1 to hit the real endpoints and
+// (re)write the file. Compressed responses are recorded decoded, and secrets
+// in headers, query parameters and JSON or form bodies are redacted.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+)
+
+// Mode decides whether a Recorder talks to the real endpoints.
+type Mode int
+
+const (
+	// Replay serves recorded responses and fails on unknown requests.
+	Replay Mode = iota
+	// Record sends requests for real and saves them when the test ends.
+	Record
+)
+
+// Redacted replaces secret values in cassettes.
+const Redacted = "REDACTED"
+
+// DefaultRedactedHeaders are never written to cassettes.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// DefaultRedactedFields are JSON fields and form parameters never written to
+// cassettes, at any depth.
+var DefaultRedactedFields = []string{"password", "access_token", "refresh_token", "client_secret", "secret"}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded part of a http.Request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is the recorded part of a http.Response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a recorded body. It is saved as a JSON string when it is valid
+// utf-8, as {"base64": "..."} otherwise, so that binary bodies round-trip.
+type Body []byte
+
+type base64Body struct {
+	Base64 []byte `json:"base64"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(base64Body{Base64: b})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var v base64Body
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*b = v.Base64
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*b = Body(s)
+	return nil
+}
+
+// Matcher tells whether a live request, already redacted, matches a recorded one.
+type Matcher func(live, recorded Request) bool
+
+// MatchMethod matches the HTTP method.
+func MatchMethod(live, recorded Request) bool {
+	return live.Method == recorded.Method
+}
+
+// MatchURL matches the whole URL including the query.
+func MatchURL(live, recorded Request) bool {
+	return live.URL == recorded.URL
+}
+
+// MatchPath matches the URL ignoring the query.
+func MatchPath(live, recorded Request) bool {
+	return strings.SplitN(live.URL, "?", 2)[0] == strings.SplitN(recorded.URL, "?", 2)[0]
+}
+
+// MatchBody matches the raw body.
+func MatchBody(live, recorded Request) bool {
+	return bytes.Equal(live.Body, recorded.Body)
+}
+
+// MatchJSONBody matches bodies as JSON, so that key order and spacing don't
+// matter. Bodies that are not JSON are compared as is.
+func MatchJSONBody(live, recorded Request) bool {
+	var a, b interface{}
+	if json.Unmarshal(live.Body, &a) != nil || json.Unmarshal(recorded.Body, &b) != nil {
+		return bytes.Equal(live.Body, recorded.Body)
+	}
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return bytes.Equal(ab, bb)
+}
+
+// MatchHeader matches the value of the given header.
+func MatchHeader(name string) Matcher {
+	return func(live, recorded Request) bool {
+		return live.Header.Get(name) == recorded.Header.Get(name)
+	}
+}
+
+// DefaultMatchers match by method, url and body.
+var DefaultMatchers = []Matcher{MatchMethod, MatchURL, MatchBody}
+
+// Option customizes a Recorder.
+type Option func(*Recorder)
+
+// WithMatchers replaces the matchers used to find a recorded interaction.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithRedactedHeaders redacts the given headers in addition to DefaultRedactedHeaders.
+func WithRedactedHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		r.redactHeaders = append(r.redactHeaders, headers...)
+	}
+}
+
+// WithRedactedQuery redacts the given query parameters, such as access tokens.
+func WithRedactedQuery(params ...string) Option {
+	return func(r *Recorder) {
+		r.redactQuery = append(r.redactQuery, params...)
+	}
+}
+
+// WithRedactedFields redacts the given JSON fields and form parameters of
+// bodies in addition to DefaultRedactedFields.
+func WithRedactedFields(fields ...string) Option {
+	return func(r *Recorder) {
+		r.redactFields = append(r.redactFields, fields...)
+	}
+}
+
+// WithMode forces the mode instead of reading AGL_CASSETTE_RECORD.
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithTransport sets the transport used in Record mode.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.real = rt
+	}
+}
+
+// WithPath overrides the location of the cassette file.
+func WithPath(path string) Option {
+	return func(r *Recorder) {
+		r.path = path
+	}
+}
+
+// Recorder is a http.RoundTripper recording or replaying interactions.
+type Recorder struct {
+	t             testing.TB
+	path          string
+	mode          Mode
+	matchers      []Matcher
+	redactHeaders []string
+	redactQuery   []string
+	redactFields  []string
+	real          http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// New creates a Recorder for the cassette of given name. The cassette is
+// saved when the test finishes in Record mode.
+func New(t testing.TB, name string, opts ...Option) *Recorder {
+	r := &Recorder{
+		t:             t,
+		path:          filepath.Join("testdata", "cassettes", name+".json"),
+		matchers:      DefaultMatchers,
+		redactHeaders: append([]string{}, DefaultRedactedHeaders...),
+		redactFields:  append([]string{}, DefaultRedactedFields...),
+		real:          http.DefaultTransport,
+	}
+	if os.Getenv("AGL_CASSETTE_RECORD") == "1" {
+		r.mode = Record
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	switch r.mode {
+	case Record:
+		t.Cleanup(func() {
+			if err := r.save(); err != nil {
+				t.Errorf("cassette: failed to save %s: %v", r.path, err)
+			}
+		})
+	case Replay:
+		if err := r.load(); err != nil {
+			t.Fatalf("cassette: failed to load %s, run with AGL_CASSETTE_RECORD=1 to record it: %v", r.path, err)
+		}
+	}
+	return r
+}
+
+// Client returns a http.Client going through the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	live, err := r.captureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == Record {
+		return r.record(req, live)
+	}
+	return r.replay(req, live)
+}
+
+func (r *Recorder) record(req *http.Request, live Request) (*http.Response, error) {
+	res, err := r.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// Record the plain body, so that it can be redacted and replayed as is.
+	if err = httpie.DecodeBody(res, httpie.BodyConf{}); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, &Interaction{
+		Request: live,
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     r.redactHeader(res.Header),
+			Body:       r.redactBody(res.Header.Get("Content-Type"), body),
+		},
+	})
+	r.used = append(r.used, true)
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, live Request) (*http.Response, error) {
+	in := r.match(live)
+	if in == nil {
+		r.t.Errorf("cassette: unmatched request in %s: %s %s", r.path, live.Method, live.URL)
+		return nil, fmt.Errorf("cassette: unmatched request: %s %s", live.Method, live.URL)
+	}
+	header := in.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// match prefers interactions not replayed yet, so that repeated identical
+// requests get the responses in the recorded order.
+func (r *Recorder) match(live Request) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var fallback *Interaction
+	for i, in := range r.interactions {
+		if !r.matches(live, in.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return in
+		}
+		if fallback == nil {
+			fallback = in
+		}
+	}
+	return fallback
+}
+
+func (r *Recorder) matches(live, recorded Request) bool {
+	for _, m := range r.matchers {
+		if !m(live, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Recorder) captureRequest(req *http.Request) (Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return Request{}, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return Request{
+		Method: req.Method,
+		URL:    r.redactURL(req.URL),
+		Header: r.redactHeader(req.Header),
+		Body:   r.redactBody(req.Header.Get("Content-Type"), body),
+	}, nil
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range r.redactHeaders {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, Redacted)
+		}
+	}
+	return h
+}
+
+func (r *Recorder) redactURL(u *url.URL) string {
+	if len(r.redactQuery) == 0 || u.RawQuery == "" {
+		return u.String()
+	}
+	u2 := *u
+	q := u2.Query()
+	for _, p := range r.redactQuery {
+		if q.Has(p) {
+			q.Set(p, Redacted)
+		}
+	}
+	u2.RawQuery = q.Encode()
+	return u2.String()
+}
+
+// redactBody redacts the fields of JSON and form bodies. Bodies without
+// redacted fields are kept byte for byte.
+func (r *Recorder) redactBody(contentType string, b []byte) Body {
+	if len(b) == 0 {
+		return nil
+	}
+	if strings.Contains(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(b)); err == nil {
+			if r.redactValues(values) {
+				return Body(values.Encode())
+			}
+			return b
+		}
+	}
+	var v interface{}
+	if json.Unmarshal(b, &v) == nil && r.redactJSON(v) {
+		if redacted, err := json.Marshal(v); err == nil {
+			return redacted
+		}
+	}
+	return b
+}
+
+func (r *Recorder) redactValues(values url.Values) bool {
+	redacted := false
+	for k := range values {
+		if r.isRedactedField(k) {
+			values[k] = []string{Redacted}
+			redacted = true
+		}
+	}
+	return redacted
+}
+
+func (r *Recorder) redactJSON(v interface{}) bool {
+	redacted := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if r.isRedactedField(k) {
+				v[k] = Redacted
+				redacted = true
+			} else if r.redactJSON(fv) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, ev := range v {
+			if r.redactJSON(ev) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+func (r *Recorder) isRedactedField(name string) bool {
+	for _, f := range r.redactFields {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	for _, p := range r.redactQuery {
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) load() error {
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, &r.interactions); err != nil {
+		return err
+	}
+	r.used = make([]bool, len(r.interactions))
+	return nil
+}
+
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
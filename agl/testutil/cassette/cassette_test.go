@@ -0,0 +1,109 @@
+package cassette_test
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leclerc04/go-tool/agl/testutil/cassette"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+	"github.com/leclerc04/go-tool/httpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, format)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	}))
+	url := server.URL + "/greet?name=tom"
+	path := filepath.Join(t.TempDir(), "greet.json")
+
+	t.Run("record", func(t *testing.T) {
+		rec := cassette.New(t, "greet", cassette.WithMode(cassette.Record), cassette.WithPath(path))
+		res, err := httpc.New(httpc.SetTransport(rec), httpc.SetAuthToken("secret")).Get(url)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello tom", res.String())
+	})
+	server.Close()
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(b), "secret"))
+	assert.True(t, strings.Contains(string(b), cassette.Redacted))
+
+	t.Run("replay", func(t *testing.T) {
+		rec := cassette.New(t, "greet", cassette.WithPath(path))
+		res, err := httpc.New(httpc.SetTransport(rec), httpc.SetAuthToken("other")).Get(url)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello tom", res.String())
+
+		body, err := httpie.NewEnvWithClient(rec.Client()).Get(context.Background(), url).String()
+		assert.NoError(t, err)
+		assert.Equal(t, "hello tom", body)
+	})
+
+	t.Run("unmatched", func(t *testing.T) {
+		rt := &recordingT{TB: t}
+		rec := cassette.New(rt, "greet", cassette.WithPath(path))
+		_, err := httpie.NewEnvWithClient(rec.Client()).Get(context.Background(), url+"&x=1").String()
+		assert.Error(t, err)
+		assert.Len(t, rt.errors, 1)
+	})
+}
+
+func TestRecordAndReplay_Bodies(t *testing.T) {
+	binary := []byte{0xff, 0xd8, 0x00, 0xc4, 0xe3}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write([]byte(`{"code":200,"data":{"access_token":"tok-123","name":"tom"}}`))
+			_ = zw.Close()
+		case "/binary":
+			_, _ = w.Write(binary)
+		}
+	}))
+	path := filepath.Join(t.TempDir(), "bodies.json")
+	login := map[string]string{"user": "tom", "password": "hunter2"}
+
+	check := func(t *testing.T, rec *cassette.Recorder, token string) {
+		c := httpc.New(httpc.SetTransport(rec))
+		res, err := c.Post(server.URL+"/gzip", httpc.SetBody(login))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"code":200,"data":{"access_token":"`+token+`","name":"tom"}}`, res.String())
+
+		res, err = c.Get(server.URL + "/binary")
+		assert.NoError(t, err)
+		assert.Equal(t, binary, res.Body())
+	}
+
+	t.Run("record", func(t *testing.T) {
+		check(t, cassette.New(t, "bodies", cassette.WithMode(cassette.Record), cassette.WithPath(path)), "tok-123")
+	})
+	server.Close()
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for _, secret := range []string{"hunter2", "tok-123", "Content-Encoding"} {
+		assert.NotContains(t, string(b), secret)
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		check(t, cassette.New(t, "bodies", cassette.WithPath(path)), cassette.Redacted)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"regexp"
+	"strings"
 
 	"github.com/haorendashu/chardet"
 	"golang.org/x/text/encoding/simplifiedchinese"
@@ -71,6 +72,20 @@ func (r *ChineseReader) Read(p []byte) (n int, err error) {
 	return r.dec.Read(p)
 }
 
+// NewUTF8Reader converts r from the named charset to utf-8. GBK, GB2312 and
+// GB18030 are converted, ok is false for other charsets which are left as is.
+func NewUTF8Reader(r io.Reader, charset string) (_ io.Reader, ok bool) {
+	switch strings.ToLower(charset) {
+	case "gbk", "gb2312", "cp936":
+		return gbkDecoder.Reader(r), true
+	case "hz-gb2312":
+		return simplifiedchinese.HZGB2312.NewDecoder().Reader(r), true
+	case "gb18030":
+		return simplifiedchinese.GB18030.NewDecoder().Reader(r), true
+	}
+	return r, false
+}
+
 var hanPattern = regexp.MustCompile(`\p{Han}`)
 
 // CleanTextWidth 全角转半角。
@@ -0,0 +1,225 @@
+package httpie
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/leclerc04/go-tool/agl/util/charsetutil"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+)
+
+// DefaultMaxBodySize is a BodyConf.MaxSize suiting most APIs, 64MiB. Bodies
+// are not limited unless asked, like in httpc.
+const DefaultMaxBodySize = 64 << 20
+
+// SubKindBodyTooLarge is the sub kind of errors returned when a body exceeds
+// BodyConf.MaxSize.
+const SubKindBodyTooLarge = "httpie:body_too_large"
+
+// IsBodyTooLarge returns true if err is due to a body exceeding BodyConf.MaxSize.
+func IsBodyTooLarge(err error) bool {
+	for err != nil {
+		if e, ok := err.(*errs.Error); ok {
+			if e.SubKind == SubKindBodyTooLarge {
+				return true
+			}
+			err = e.Err
+			continue
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+// BodyConf configures how DecodeTransport decodes response bodies.
+type BodyConf struct {
+	// MaxSize fails reading a body after that many decoded bytes, or from the
+	// first read if Content-Length is already larger. Zero means no limit.
+	MaxSize int64
+	// ConvertCharset converts GBK, GB2312 and GB18030 bodies to utf-8 as
+	// declared by their Content-Type.
+	ConvertCharset bool
+	// DetectCharset also detects the charset of text bodies which don't
+	// declare one, see charsetutil.ChineseReader.
+	DetectCharset bool
+}
+
+// DecodeTransport asks for and decodes gzip, deflate and br bodies, converts
+// legacy Chinese charsets to utf-8, and limits the size of bodies.
+type DecodeTransport struct {
+	Base http.RoundTripper // Base is http.DefaultTransport if nil.
+	BodyConf
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *DecodeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Like http.Transport, don't ask for a compressed range of the body.
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = DecodeBody(res, t.BodyConf); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// DecodeBody replaces res.Body with its decoded content, and removes the
+// headers which don't describe it anymore.
+func DecodeBody(res *http.Response, conf BodyConf) error {
+	body, length := res.Body, res.ContentLength
+	var r io.Reader = body
+	switch strings.ToLower(res.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			if err == io.EOF {
+				// Empty body, e.g. a HEAD request.
+				break
+			}
+			return errs.Wrapf(err, "invalid gzip body")
+		}
+		r = zr
+		uncompressed(res)
+	case "deflate":
+		dr, err := deflateReader(body)
+		if err != nil {
+			return errs.Wrapf(err, "invalid deflate body")
+		}
+		r = dr
+		uncompressed(res)
+	case "br":
+		r = brotli.NewReader(body)
+		uncompressed(res)
+	}
+
+	if conf.ConvertCharset || conf.DetectCharset {
+		r = convertCharset(res, r, conf.DetectCharset)
+	}
+	if conf.MaxSize > 0 {
+		r = &limitedReader{r: r, max: conf.MaxSize, length: length}
+	}
+	if r != io.Reader(body) {
+		res.Body = &decodedBody{Reader: r, Closer: body}
+	}
+	return nil
+}
+
+// deflateReader decodes a zlib stream as the RFC says, or raw deflate as
+// some servers send.
+func deflateReader(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 0 {
+		// Empty body, e.g. a HEAD request.
+		return br, nil
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+func uncompressed(res *http.Response) {
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+}
+
+func convertCharset(res *http.Response, r io.Reader, detect bool) io.Reader {
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return r
+	}
+	if charset := params["charset"]; charset != "" {
+		if cr, ok := charsetutil.NewUTF8Reader(r, charset); ok {
+			params["charset"] = "utf-8"
+			res.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+			return cr
+		}
+		return r
+	}
+	if detect && isText(mediaType) {
+		return charsetutil.NewChineseReader(r)
+	}
+	return r
+}
+
+func isText(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") || mediaType == "application/x-www-form-urlencoded"
+}
+
+// Unlimited lifts the size limit of a body decoded by DecodeTransport, for
+// callers streaming it instead of reading it in memory.
+func Unlimited(body io.ReadCloser) io.ReadCloser {
+	if b, ok := body.(*decodedBody); ok {
+		if l, ok := b.Reader.(*limitedReader); ok {
+			l.max = 0
+		}
+	}
+	return body
+}
+
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// limitedReader fails once more than max bytes are read, unless max is 0.
+// It fails on the first Read if the Content-Length is already larger.
+type limitedReader struct {
+	r      io.Reader
+	n      int64
+	max    int64
+	length int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.max > 0 && l.length > l.max {
+		return 0, bodyTooLarge(l.max)
+	}
+	if l.max > 0 && l.n >= l.max {
+		// Read one more byte to tell a body of exactly max bytes from a larger one.
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, bodyTooLarge(l.max)
+		}
+		return 0, err
+	}
+	if l.max > 0 && int64(len(p)) > l.max-l.n {
+		p = p[:l.max-l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+func bodyTooLarge(max int64) error {
+	return errs.WithSubKind(errs.Newf("http: response body larger than %d bytes", max), SubKindBodyTooLarge)
+}
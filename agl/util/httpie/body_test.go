@@ -0,0 +1,108 @@
+package httpie
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func compress(t *testing.T, encoding string, s string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		// Sent as deflate by some servers.
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecodeTransport(t *testing.T) {
+	const text = "你好, 世界"
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(text)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := r.URL.Query().Get("enc")
+		body := []byte(text)
+		switch {
+		case r.URL.Path == "/gbk":
+			w.Header().Set("Content-Type", "text/plain; charset=GBK")
+			body = []byte(gbk)
+		case r.URL.Path == "/large":
+			body = bytes.Repeat([]byte("x"), 100)
+		}
+		if enc != "" {
+			header := strings.TrimPrefix(enc, "raw-")
+			assert.Contains(t, r.Header.Get("Accept-Encoding"), header)
+			w.Header().Set("Content-Encoding", header)
+			body = compress(t, enc, string(body))
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	env := NewEnvWithClient(&http.Client{Transport: &DecodeTransport{BodyConf: BodyConf{MaxSize: 64, ConvertCharset: true}}})
+	ctx := context.Background()
+	for _, enc := range []string{"", "gzip", "deflate", "raw-deflate", "br"} {
+		s, err := env.Get(ctx, srv.URL+"/?enc="+enc).String()
+		assert.NoError(t, err, enc)
+		assert.Equal(t, text, s, enc)
+
+		s, err = env.Get(ctx, srv.URL+"/gbk?enc="+enc).String()
+		assert.NoError(t, err, enc)
+		assert.Equal(t, text, s, enc)
+
+		_, err = env.Get(ctx, srv.URL+"/large?enc="+enc).Bytes()
+		assert.True(t, IsBodyTooLarge(err), "%s: %v", enc, err)
+
+		body, err := env.Get(ctx, srv.URL+"/large?enc="+enc).Stream()
+		require.NoError(t, err)
+		b, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Len(t, b, 100)
+		_ = body.Close()
+	}
+
+	res, err := env.Get(ctx, srv.URL+"/gbk").Header()
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", res.Get("Content-Type"))
+
+	// exactly at the limit
+	env = NewEnvWithClient(&http.Client{Transport: &DecodeTransport{BodyConf: BodyConf{MaxSize: 100}}})
+	b, err := env.Get(ctx, srv.URL+"/large").Bytes()
+	assert.NoError(t, err)
+	assert.Len(t, b, 100)
+}
+
+func TestDecodeTransport_ContentLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer srv.Close()
+
+	env := NewEnvWithClient(&http.Client{Transport: &DecodeTransport{BodyConf: BodyConf{MaxSize: 10}}})
+	_, err := env.Get(context.Background(), srv.URL).Bytes()
+	assert.True(t, IsBodyTooLarge(err), "%v", err)
+}
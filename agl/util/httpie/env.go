@@ -31,35 +31,50 @@ type Env struct {
 	Client *http.Client
 }
 
-// NewEnv creates a new http Env.
+// NewEnv creates a new http Env. Its bodies are decompressed but not limited,
+// see DecodeTransport to set a BodyConf.MaxSize.
 func NewEnv() *Env {
-	proxyURL := os.Getenv("AGL_PROXY_URL")
-	proxyRulePattern := regexp.MustCompile(os.Getenv("AGL_PROXY_RULE_PATTERN"))
 	return &Env{
 		Client: &http.Client{
-			Transport: &http.Transport{
-				Proxy: func(req *http.Request) (*url.URL, error) {
-					if proxyURL != "" && proxyRulePattern.Match([]byte(req.URL.Hostname())) {
-						return url.Parse("http://" + proxyURL)
-					}
-					return http.ProxyFromEnvironment(req)
+			Transport: &DecodeTransport{
+				Base: &http.Transport{
+					Proxy: ProxyFromEnv(),
+					DialContext: (&net.Dialer{
+						Timeout:   30 * time.Second,
+						KeepAlive: 30 * time.Second,
+						DualStack: true,
+					}).DialContext,
+					ForceAttemptHTTP2:     true,
+					MaxIdleConns:          100,
+					IdleConnTimeout:       90 * time.Second,
+					TLSHandshakeTimeout:   10 * time.Second,
+					ExpectContinueTimeout: 1 * time.Second,
 				},
-				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
-					KeepAlive: 30 * time.Second,
-					DualStack: true,
-				}).DialContext,
-				ForceAttemptHTTP2:     true,
-				MaxIdleConns:          100,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
 			},
 			Timeout: 5 * time.Minute,
 		},
 	}
 }
 
+// ProxyFromEnv sends requests to hosts matching AGL_PROXY_RULE_PATTERN through
+// the AGL_PROXY_URL proxy, and other requests as http.ProxyFromEnvironment.
+func ProxyFromEnv() func(*http.Request) (*url.URL, error) {
+	return ProxyByRule(os.Getenv("AGL_PROXY_URL"), os.Getenv("AGL_PROXY_RULE_PATTERN"))
+}
+
+// ProxyByRule sends requests to hosts matching pattern through proxyURL, a
+// host:port, and other requests as http.ProxyFromEnvironment. An empty pattern
+// matches every host.
+func ProxyByRule(proxyURL, pattern string) func(*http.Request) (*url.URL, error) {
+	proxyRulePattern := regexp.MustCompile(pattern)
+	return func(req *http.Request) (*url.URL, error) {
+		if proxyURL != "" && proxyRulePattern.Match([]byte(req.URL.Hostname())) {
+			return url.Parse("http://" + proxyURL)
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+}
+
 // NewEnvWithClient creates a new http env with given client.
 func NewEnvWithClient(hc *http.Client) *Env {
 	return &Env{
@@ -70,6 +70,15 @@ func (r *Response) Bytes() ([]byte, error) {
 	return b, err
 }
 
+// Stream returns the body for the caller to read and close, without the size
+// limit of DecodeTransport. Error statuses are returned as in Consume.
+func (r *Response) Stream() (io.ReadCloser, error) {
+	if r.err != nil || r.r.StatusCode >= 400 {
+		return nil, r.Consume(nil)
+	}
+	return Unlimited(r.r.Body), nil
+}
+
 // String returns body as string.
 func (r *Response) String() (string, error) {
 	b, err := r.Bytes()
@@ -0,0 +1,38 @@
+package httpie
+
+import "net/http"
+
+// Signer signs outbound requests, e.g. oauthutil.Signer or oauthutil.HMACSigner.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignedTransport signs every request before sending it with Base.
+type SignedTransport struct {
+	Signer Signer
+	Base   http.RoundTripper // Base is http.DefaultTransport if nil.
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *SignedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	req = req.Clone(req.Context())
+	if err := t.Signer.Sign(req); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// WithSigner returns a copy of env whose requests are signed by s.
+func (env *Env) WithSigner(s Signer) *Env {
+	hc := *env.Client
+	hc.Transport = &SignedTransport{Signer: s, Base: hc.Transport}
+	return &Env{Client: &hc}
+}
@@ -0,0 +1,147 @@
+// Package i18n holds localized messages keyed by code and negotiates the
+// language of a request.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Catalog holds the messages of codes in several languages.
+//
+// A message may contain parameters like {name}, replaced when it is rendered.
+type Catalog struct {
+	// Default is the language used when none of the requested ones has a
+	// message for the code.
+	Default language.Tag
+	// Parent, if set, is looked up for codes missing in the catalog, in each
+	// language before falling back to the next one.
+	Parent *Catalog
+
+	mu       sync.RWMutex
+	messages map[language.Tag]map[int]string
+}
+
+// NewCatalog creates an empty catalog.
+func NewCatalog(def language.Tag) *Catalog {
+	return &Catalog{Default: def, messages: map[language.Tag]map[int]string{}}
+}
+
+// Set sets the message of code in lang.
+func (c *Catalog) Set(lang language.Tag, code int, msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.messages[lang]
+	if !ok {
+		m = map[int]string{}
+		c.messages[lang] = m
+	}
+	m[code] = msg
+}
+
+// Load reads the files matching pattern in fsys, each named after its
+// language, e.g. "locales/en.json", and holding a JSON object from codes to
+// messages: {"404": "Not found"}.
+func (c *Catalog) Load(fsys fs.FS, pattern string) error {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		base := path.Base(name)
+		lang, err := language.Parse(strings.TrimSuffix(base, path.Ext(base)))
+		if err != nil {
+			return fmt.Errorf("i18n: %s: %w", name, err)
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		var msgs map[string]string
+		if err = json.Unmarshal(b, &msgs); err != nil {
+			return fmt.Errorf("i18n: %s: %w", name, err)
+		}
+		for k, msg := range msgs {
+			code, err := strconv.Atoi(k)
+			if err != nil {
+				return fmt.Errorf("i18n: %s: invalid code %q", name, k)
+			}
+			c.Set(lang, code, msg)
+		}
+	}
+	return nil
+}
+
+// MustLoad is like Load but panics on error, for embedded files.
+func (c *Catalog) MustLoad(fsys fs.FS, pattern string) *Catalog {
+	if err := c.Load(fsys, pattern); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Message returns the message of code in the first of langs having one,
+// rendered with params. Each language falls back to its parents, e.g.
+// zh-Hans-CN to zh-Hans then zh, and the Default language is tried last.
+func (c *Catalog) Message(langs []language.Tag, code int, params map[string]any) (string, bool) {
+	for i := 0; i <= len(langs); i++ {
+		lang := c.Default
+		if i < len(langs) {
+			lang = langs[i]
+		}
+		for ; ; lang = lang.Parent() {
+			if msg, ok := c.lookup(lang, code); ok {
+				return Render(msg, params), true
+			}
+			if lang.IsRoot() {
+				break
+			}
+		}
+	}
+	return "", false
+}
+
+func (c *Catalog) lookup(lang language.Tag, code int) (string, bool) {
+	c.mu.RLock()
+	msg, ok := c.messages[lang][code]
+	c.mu.RUnlock()
+	if !ok && c.Parent != nil {
+		return c.Parent.lookup(lang, code)
+	}
+	return msg, ok
+}
+
+// Render replaces the {name} parameters of msg by their value in params.
+// Unknown parameters are kept as is.
+func Render(msg string, params map[string]any) string {
+	if len(params) == 0 || !strings.Contains(msg, "{") {
+		return msg
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(msg, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(msg[i:], '}')
+		if j < 0 {
+			break
+		}
+		b.WriteString(msg[:i])
+		if v, ok := params[msg[i+1:i+j]]; ok {
+			fmt.Fprint(&b, v)
+		} else {
+			b.WriteString(msg[i : i+j+1])
+		}
+		msg = msg[i+j+1:]
+	}
+	b.WriteString(msg)
+	return b.String()
+}
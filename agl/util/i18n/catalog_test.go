@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestCatalog_Message(t *testing.T) {
+	parent := NewCatalog(language.Chinese)
+	parent.Set(language.Chinese, 500, "服务繁忙")
+	parent.Set(language.English, 500, "Busy")
+
+	c := NewCatalog(language.Chinese)
+	c.Parent = parent
+	assert.NoError(t, c.Load(fstest.MapFS{
+		"locales/zh.json":    {Data: []byte(`{"404": "{name} 不存在"}`)},
+		"locales/en.json":    {Data: []byte(`{"404": "{name} not found"}`)},
+		"locales/en-GB.json": {Data: []byte(`{"409": "Already exists, {name}"}`)},
+	}, "locales/*.json"))
+
+	for _, tc := range []struct {
+		langs string
+		code  int
+		want  string
+	}{
+		{"en-US,en;q=0.8", 404, "bob not found"},
+		{"en-GB", 409, "Already exists, bob"},
+		{"en-GB", 404, "bob not found"},
+		{"fr, en;q=0.5", 500, "Busy"},
+		{"fr", 404, "bob 不存在"},
+		{"", 500, "服务繁忙"},
+	} {
+		msg, ok := c.Message(ParseAcceptLanguage(tc.langs), tc.code, map[string]any{"name": "bob"})
+		assert.True(t, ok, tc.langs)
+		assert.Equal(t, tc.want, msg, tc.langs)
+	}
+	_, ok := c.Message(nil, 409, nil)
+	assert.False(t, ok)
+
+	err := c.Load(fstest.MapFS{"x.json": {Data: []byte(`{"abc": "x"}`)}}, "*.json")
+	assert.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	assert.Equal(t, "hi bob, {unknown} {", Render("hi {name}, {unknown} {", map[string]any{"name": "bob"}))
+	assert.Equal(t, "3 items", Render("{n} items", map[string]any{"n": 3}))
+}
+
+func TestRequestLanguages(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "en;q=0.5, zh-CN")
+	assert.Equal(t, []language.Tag{language.MustParse("zh-CN"), language.English}, RequestLanguages(r))
+
+	r = r.WithContext(WithLanguages(context.Background(), language.Japanese))
+	assert.Equal(t, []language.Tag{language.Japanese}, RequestLanguages(r))
+}
@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/leclerc04/go-tool/agl/util/contextutil"
+	"golang.org/x/text/language"
+)
+
+var languagesKey = contextutil.NewIface()
+
+// WithLanguages returns a context preferring langs, e.g. from the settings of
+// the user. It takes precedence over the Accept-Language header.
+func WithLanguages(ctx context.Context, langs ...language.Tag) context.Context {
+	return languagesKey.WithInterface(ctx, langs)
+}
+
+// Languages returns the languages set by WithLanguages.
+func Languages(ctx context.Context) []language.Tag {
+	v := languagesKey.Interface(ctx)
+	if v == nil {
+		return nil
+	}
+	return v.([]language.Tag)
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header by
+// decreasing preference, nil if it is invalid.
+func ParseAcceptLanguage(header string) []language.Tag {
+	if header == "" {
+		return nil
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil
+	}
+	return tags
+}
+
+// RequestLanguages returns the languages of the context of r, or else of its
+// Accept-Language header.
+func RequestLanguages(r *http.Request) []language.Tag {
+	if langs := Languages(r.Context()); len(langs) > 0 {
+		return langs
+	}
+	return ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
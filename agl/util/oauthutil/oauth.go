@@ -2,7 +2,8 @@ package oauthutil
 
 import (
 	"bytes"
-	"fmt"
+	"crypto/sha1"
+	"crypto/sha256"
 	"io/ioutil"
 	"mime"
 	"net/http"
@@ -27,11 +28,17 @@ const (
 	oauthNonceParamKey           = "oauth_nonce"
 	oauthVersionParamKey         = "oauth_version"
 
-	oauthDefaultVersion      = "1.0"
-	signatureMethodPlainText = "PLAINTEXT"
-	signatureMethodHmacSha1  = "HMAC-SHA1"
-	signatureMethodRsaSha1   = "RSA-SHA1"
-	authorizationPrefix      = "OAuth " // trailing space is intentional
+	oauthDefaultVersion = "1.0"
+	authorizationPrefix = "OAuth " // trailing space is intentional
+)
+
+// Signature methods of RFC 5849 section 3.4, plus HMAC-SHA256 which is
+// commonly accepted by partners.
+const (
+	SignatureMethodPlainText  = "PLAINTEXT"
+	SignatureMethodHmacSha1   = "HMAC-SHA1"
+	SignatureMethodHmacSha256 = "HMAC-SHA256"
+	SignatureMethodRsaSha1    = "RSA-SHA1"
 )
 
 var (
@@ -134,7 +141,7 @@ func Verify(r *Request, store Store) error {
 
 	// check timestamp
 	// RFC 5849 section 3.1[1]: timestamp or nonce on PLAINTEXT is optional.
-	if !(r.SignatureMethod() == signatureMethodPlainText && r.Timestamp() == "") {
+	if !(r.SignatureMethod() == SignatureMethodPlainText && r.Timestamp() == "") {
 		ts, err := strconv.Atoi(r.Timestamp())
 		if err != nil {
 			return errs.InvalidArgument.Newf("timestamp invalid: %s Error: %v", r.Timestamp(), err)
@@ -146,7 +153,7 @@ func Verify(r *Request, store Store) error {
 	}
 
 	// check nonce
-	if !(r.SignatureMethod() == signatureMethodPlainText && (r.Timestamp() == "" || r.Nonce() == "")) {
+	if !(r.SignatureMethod() == SignatureMethodPlainText && (r.Timestamp() == "" || r.Nonce() == "")) {
 		unique, err := store.CheckUniqueness(r.Nonce(), r.Timestamp(), r.Token())
 		if err != nil {
 			return err
@@ -159,22 +166,30 @@ func Verify(r *Request, store Store) error {
 	// check signature
 	method := r.SignatureMethod()
 	switch method {
-	case signatureMethodPlainText:
+	case SignatureMethodPlainText:
 		if !safeEqual(
 			signatureBaseStringPlainText(consumerSecret, tokenSecret),
 			r.signature) {
 			return errs.Forbidden.Newf("token secret")
 		}
 		return nil
-	case signatureMethodHmacSha1:
-		if !checkHMACSHA1(
+	case SignatureMethodHmacSha1:
+		if !checkHMAC(sha1.New,
+			r.signatureBaseString(),
+			signatureBaseStringPlainText(consumerSecret, tokenSecret),
+			r.signature) {
+			return errs.Forbidden.Newf("token secret")
+		}
+		return nil
+	case SignatureMethodHmacSha256:
+		if !checkHMAC(sha256.New,
 			r.signatureBaseString(),
 			signatureBaseStringPlainText(consumerSecret, tokenSecret),
 			r.signature) {
 			return errs.Forbidden.Newf("token secret")
 		}
 		return nil
-	case signatureMethodRsaSha1:
+	case SignatureMethodRsaSha1:
 		return errs.InvalidArgument.Newf("RSA-SHA1 signature method not supported.")
 	default:
 		return errs.InvalidArgument.Newf("Unknown signature method: %s", method)
@@ -182,10 +197,10 @@ func Verify(r *Request, store Store) error {
 }
 
 func (r *Request) signatureBaseString() string {
-	return fmt.Sprintf("%s&%s&%s",
-		strings.ToUpper(r.HTTPRequest.Method),
-		percentEncode(baseStringURI(r.HTTPRequest.Host, r.HTTPRequest.URL.Path, r.HTTPRequest.TLS != nil)),
-		percentEncode(formatRequestParametersForSigning(r.oauthParameters, r.otherParameters)),
+	return signatureBaseString(
+		r.HTTPRequest.Method,
+		baseStringURI(r.HTTPRequest.Host, r.HTTPRequest.URL.Path, r.HTTPRequest.TLS != nil),
+		r.oauthParameters, r.otherParameters,
 	)
 }
 
@@ -240,7 +255,7 @@ func (r *Request) parseRequestBody() (oauthParams url.Values, otherParams url.Va
 		return nil, nil, nil
 	}
 	ct, _, err := mime.ParseMediaType(r.HTTPRequest.Header.Get("Content-Type"))
-	if err != nil || ct != "application/x-www-form-urlencoded" {
+	if err != nil || ct != "application/x-www-form-urlencoded" || r.HTTPRequest.Body == nil {
 		return nil, nil, nil
 	}
 
@@ -0,0 +1,111 @@
+package oauthutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/strs"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+)
+
+// Signer signs outgoing requests with OAuth 1.0, it is the client side of Verify.
+type Signer struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	// Token and TokenSecret are empty for two-legged requests.
+	Token       string
+	TokenSecret string
+	// SignatureMethod is one of the SignatureMethod constants, HMAC-SHA1 if empty.
+	SignatureMethod string
+	// PrivateKey is required by RSA-SHA1.
+	PrivateKey *rsa.PrivateKey
+	Realm      string
+}
+
+// Sign sets the OAuth Authorization header of req. The url query and a form
+// encoded POST body are covered by the signature.
+func (s *Signer) Sign(req *http.Request) error {
+	method := s.SignatureMethod
+	if method == "" {
+		method = SignatureMethodHmacSha1
+	}
+	r := &Request{HTTPRequest: req, oauthParameters: make(url.Values)}
+	r.oauthParameters.Set(oauthConsumerKeyParamKey, s.ConsumerKey)
+	if s.Token != "" {
+		r.oauthParameters.Set(oauthTokenParamKey, s.Token)
+	}
+	r.oauthParameters.Set(oauthSignatureMethodParamKey, method)
+	r.oauthParameters.Set(oauthTimestampParamKey, strconv.FormatInt(timeutil.Now().Unix(), 10))
+	r.oauthParameters.Set(oauthNonceParamKey, strs.GenerateRandomStringURLSafe(32))
+	r.oauthParameters.Set(oauthVersionParamKey, oauthDefaultVersion)
+
+	_, bodyParams, err := r.parseRequestBody()
+	if err != nil {
+		return err
+	}
+	_, queryParams := r.parseRequestURLQuery()
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	base := signatureBaseString(req.Method,
+		baseStringURI(host, req.URL.Path, req.URL.Scheme == "https"),
+		r.oauthParameters, bodyParams, queryParams)
+	key := signatureBaseStringPlainText(s.ConsumerSecret, s.TokenSecret)
+
+	switch method {
+	case SignatureMethodPlainText:
+		r.signature = key
+	case SignatureMethodHmacSha1:
+		r.signature = signHMAC(sha1.New, base, key)
+	case SignatureMethodHmacSha256:
+		r.signature = signHMAC(sha256.New, base, key)
+	case SignatureMethodRsaSha1:
+		if s.PrivateKey == nil {
+			return errs.InvalidArgument.Newf("RSA-SHA1 signature method requires a private key.")
+		}
+		sum := sha1.Sum([]byte(base))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA1, sum[:])
+		if err != nil {
+			return errs.Wrap(err)
+		}
+		r.signature = base64.StdEncoding.EncodeToString(sig)
+	default:
+		return errs.InvalidArgument.Newf("Unknown signature method: %s", method)
+	}
+
+	req.Header.Set("Authorization", r.authorizationHeader(s.Realm))
+	return nil
+}
+
+// authorizationHeader formats the oauth parameters and the signature in the
+// form parsed by parseAuthorizationHeader.
+func (r *Request) authorizationHeader(realm string) string {
+	keys := make([]string, 0, len(r.oauthParameters))
+	for k := range r.oauthParameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make([]string, 0, len(keys)+2)
+	if realm != "" {
+		params = append(params, fmt.Sprintf(`realm="%s"`, percentEncode(realm)))
+	}
+	for _, k := range keys {
+		params = append(params, fmt.Sprintf(`%s="%s"`, k, percentEncode(r.oauthParameters.Get(k))))
+	}
+	params = append(params, fmt.Sprintf(`%s="%s"`, oauthSignatureParamKey, percentEncode(r.signature)))
+	return authorizationPrefix + strings.Join(params, ", ")
+}
@@ -0,0 +1,157 @@
+package oauthutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leclerc04/go-tool/agl/util/errs"
+)
+
+type signerStore struct {
+	nonces map[string]bool
+}
+
+func (s *signerStore) GetConsumerSecret(token string) (string, error) {
+	if token == "consumer" {
+		return "consumer secret", nil
+	}
+	return "", nil
+}
+
+func (s *signerStore) GetTokenSecret(token string) (string, error) {
+	if token == "token" {
+		return "token secret", nil
+	}
+	return "", nil
+}
+
+func (s *signerStore) GetSecret(key string) (string, error) {
+	return s.GetConsumerSecret(key)
+}
+
+func (s *signerStore) CheckUniqueness(nonce, timestamp, token string) (bool, error) {
+	if s.nonces[nonce] {
+		return false, nil
+	}
+	s.nonces[nonce] = true
+	return true, nil
+}
+
+func TestSigner(t *testing.T) {
+	store := &signerStore{nonces: map[string]bool{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		or, err := ParseRequest(r)
+		if err == nil {
+			err = Verify(or, store)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), errs.GetKind(err).HTTPStatusCode())
+		}
+	}))
+	defer srv.Close()
+
+	params := url.Values{"foo": {"bar"}, "dup": {"b", "a"}, "中文": {"参数 值!*"}}
+	for _, method := range []string{SignatureMethodHmacSha1, SignatureMethodHmacSha256, SignatureMethodPlainText} {
+		for _, secret := range []string{"consumer secret", "bad"} {
+			s := &Signer{
+				ConsumerKey: "consumer", ConsumerSecret: secret,
+				Token: "token", TokenSecret: "token secret",
+				SignatureMethod: method, Realm: "Example",
+			}
+			want := http.StatusOK
+			if secret == "bad" {
+				want = http.StatusForbidden
+			}
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/get?"+params.Encode(), nil)
+			require.NoError(t, err)
+			require.NoError(t, s.Sign(req))
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+			assert.Equal(t, want, res.StatusCode, method)
+
+			req, err = http.NewRequest(http.MethodPost, srv.URL+"/post", strings.NewReader(params.Encode()))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			require.NoError(t, s.Sign(req))
+			res, err = http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+			assert.Equal(t, want, res.StatusCode, method)
+		}
+	}
+}
+
+func TestSigner_RSASHA1(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	s := &Signer{ConsumerKey: "consumer", SignatureMethod: SignatureMethodRsaSha1}
+	req, err := http.NewRequest(http.MethodGet, "https://Example.com:443/a b?x=1", nil)
+	require.NoError(t, err)
+	assert.True(t, errs.InvalidArgument.Is(s.Sign(req)))
+
+	s.PrivateKey = key
+	require.NoError(t, s.Sign(req))
+	or, err := ParseRequest(req)
+	require.NoError(t, err)
+	base := signatureBaseString(req.Method, baseStringURI(req.URL.Host, req.URL.Path, true), or.oauthParameters, or.otherParameters)
+	assert.True(t, strings.HasPrefix(base, "GET&https%3A%2F%2Fexample.com%2Fa%2520b&"), base)
+
+	sig, err := base64.StdEncoding.DecodeString(or.signature)
+	require.NoError(t, err)
+	sum := sha1.Sum([]byte(base))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, sum[:], sig))
+}
+
+func TestHMACSigner(t *testing.T) {
+	store := &signerStore{nonces: map[string]bool{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyHMAC(r, store); err != nil {
+			http.Error(w, err.Error(), errs.GetKind(err).HTTPStatusCode())
+		}
+	}))
+	defer srv.Close()
+
+	send := func(s *HMACSigner, body string, tamper func(*http.Request)) int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/internal?b=2&a=1", strings.NewReader(body))
+		require.NoError(t, err)
+		require.NoError(t, s.Sign(req))
+		if tamper != nil {
+			tamper(req)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		return res.StatusCode
+	}
+
+	good := &HMACSigner{Key: "consumer", Secret: "consumer secret"}
+	assert.Equal(t, http.StatusOK, send(good, `{"x":1}`, nil))
+	assert.Equal(t, http.StatusOK, send(good, "", nil))
+	assert.Equal(t, http.StatusForbidden, send(&HMACSigner{Key: "consumer", Secret: "bad"}, "", nil))
+	assert.Equal(t, http.StatusForbidden, send(&HMACSigner{Key: "unknown", Secret: "x"}, "", nil))
+	assert.Equal(t, http.StatusForbidden, send(good, `{"x":1}`, func(r *http.Request) {
+		r.Body = http.NoBody
+		r.ContentLength = 0
+	}))
+	assert.Equal(t, http.StatusForbidden, send(good, "", func(r *http.Request) {
+		r.URL.RawQuery = "a=2"
+	}))
+	// replayed nonce
+	assert.Equal(t, http.StatusForbidden, send(good, "", func(r *http.Request) {
+		store.nonces[r.Header.Get(HMACNonceHeader)] = true
+	}))
+}
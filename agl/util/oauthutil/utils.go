@@ -2,10 +2,10 @@ package oauthutil
 
 import (
 	"crypto/hmac"
-	"crypto/sha1"
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"net/url"
 	"sort"
 	"strings"
@@ -96,14 +96,27 @@ func splitParams(p url.Values) (oauthParams url.Values, otherParams url.Values)
 	return
 }
 
-func checkHMACSHA1(message, key, signature string) bool {
-	hashfun := hmac.New(sha1.New, []byte(key))
+// signatureBaseString builds the signature base string of RFC 5849 3.4.1.
+func signatureBaseString(method, baseURI string, params ...url.Values) string {
+	return fmt.Sprintf("%s&%s&%s",
+		strings.ToUpper(method),
+		percentEncode(baseURI),
+		percentEncode(formatRequestParametersForSigning(params...)),
+	)
+}
+
+// signHMAC returns the base64 encoded HMAC of message.
+func signHMAC(h func() hash.Hash, message, key string) string {
+	hashfun := hmac.New(h, []byte(key))
 	_, err := hashfun.Write([]byte(message))
 	if err != nil {
 		panic(err)
 	}
-	sig := base64.StdEncoding.EncodeToString(hashfun.Sum(nil))
-	return safeEqual(signature, sig)
+	return base64.StdEncoding.EncodeToString(hashfun.Sum(nil))
+}
+
+func checkHMAC(h func() hash.Hash, message, key, signature string) bool {
+	return safeEqual(signature, signHMAC(h, message, key))
 }
 
 // safeEqual uses ConstantTimeCompare to prevent against timing attack.
@@ -0,0 +1,90 @@
+package openai
+
+// Accumulator aggregates the chunks of a stream into a Response.
+type Accumulator struct {
+	res Response
+}
+
+// Add merges chunk: contents and function arguments are appended, tool
+// calls are matched by their index, and the finish reasons and usage are
+// kept.
+func (a *Accumulator) Add(chunk *Chunk) {
+	if a.res.ID == "" {
+		a.res.ID, a.res.Created, a.res.Model = chunk.ID, chunk.Created, chunk.Model
+		a.res.Object = "chat.completion"
+	}
+	if chunk.Usage != nil {
+		usage := *chunk.Usage
+		a.res.Usage = &usage
+	}
+	for _, cc := range chunk.Choices {
+		c := a.choice(cc.Index)
+		if cc.Delta.Role != "" {
+			c.Message.Role = cc.Delta.Role
+		}
+		c.Message.Content += cc.Delta.Content
+		for _, delta := range cc.Delta.ToolCalls {
+			call := toolCall(&c.Message, delta.Index)
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			if delta.Type != "" {
+				call.Type = delta.Type
+			}
+			call.Function.Name += delta.Function.Name
+			call.Function.Arguments += delta.Function.Arguments
+		}
+		if cc.FinishReason != "" {
+			c.FinishReason = cc.FinishReason
+		}
+	}
+}
+
+// Response returns the aggregated response.
+func (a *Accumulator) Response() *Response {
+	res := a.res
+	res.Choices = append([]Choice(nil), a.res.Choices...)
+	for i := range res.Choices {
+		calls := res.Choices[i].Message.ToolCalls
+		res.Choices[i].Message.ToolCalls = make([]ToolCall, len(calls))
+		for j, call := range calls {
+			// Indexes only make sense in deltas.
+			call.Index = nil
+			res.Choices[i].Message.ToolCalls[j] = call
+		}
+		if len(calls) == 0 {
+			res.Choices[i].Message.ToolCalls = nil
+		}
+	}
+	return &res
+}
+
+func (a *Accumulator) choice(index int) *Choice {
+	for i := range a.res.Choices {
+		if a.res.Choices[i].Index == index {
+			return &a.res.Choices[i]
+		}
+	}
+	a.res.Choices = append(a.res.Choices, Choice{Index: index})
+	return &a.res.Choices[len(a.res.Choices)-1]
+}
+
+// toolCall returns the call of m at index, or a new one. Deltas without
+// index continue the last call.
+func toolCall(m *Message, index *int) *ToolCall {
+	if index == nil {
+		if len(m.ToolCalls) > 0 {
+			return &m.ToolCalls[len(m.ToolCalls)-1]
+		}
+		zero := 0
+		index = &zero
+	}
+	for i := range m.ToolCalls {
+		if c := m.ToolCalls[i].Index; c != nil && *c == *index {
+			return &m.ToolCalls[i]
+		}
+	}
+	i := *index
+	m.ToolCalls = append(m.ToolCalls, ToolCall{Index: &i})
+	return &m.ToolCalls[len(m.ToolCalls)-1]
+}
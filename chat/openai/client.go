@@ -0,0 +1,221 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/chat/sse"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/leclerc04/go-tool/httpc"
+)
+
+// CompletionsPath is the path of the chat completion endpoint, relative to
+// the base URL, e.g. "https://api.openai.com/v1".
+const CompletionsPath = "/chat/completions"
+
+// doneData ends a stream.
+const doneData = "[DONE]"
+
+// Client calls an OpenAI compatible API.
+type Client struct {
+	c *httpc.Client
+	// stream sends the requests of streams. It has no timeout, as the one
+	// of http.Client also covers reading the body: streams are limited by
+	// their context or chat.StreamConf.Timeout instead.
+	stream *httpc.Client
+}
+
+// NewClient creates a client of the API at baseURL, authenticated by apiKey
+// if not empty. cfs configure the underlying httpc clients.
+func NewClient(baseURL, apiKey string, cfs ...httpc.ClientFunc) *Client {
+	cfs = append([]httpc.ClientFunc{httpc.SetBaseURI(strings.TrimSuffix(baseURL, "/"))}, cfs...)
+	if apiKey != "" {
+		cfs = append(cfs, httpc.SetAuthToken(apiKey))
+	}
+	return &Client{
+		c:      httpc.New(cfs...),
+		stream: httpc.New(append(cfs[:len(cfs):len(cfs)], httpc.UnsetTimeout())...),
+	}
+}
+
+// CreateChatCompletion sends req and returns the completion.
+func (c *Client) CreateChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	req.Stream, req.StreamOptions = false, nil
+	var res Response
+	raw, err := c.c.PostCtx(ctx, CompletionsPath, httpc.SetBody(req), httpc.SetResult(&res))
+	if err != nil {
+		if raw == nil {
+			return nil, err
+		}
+		return nil, apiError(raw, raw.Body(), err)
+	}
+	return &res, nil
+}
+
+// CreateChatCompletionStream sends req and returns the stream of its
+// completion, which must be closed. Usage is requested unless
+// req.StreamOptions is set.
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req Request) (*Stream, error) {
+	res, err := c.openStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{body: res.Body, r: sse.NewReader(res.Body)}, nil
+}
+
+// openStream sends req for a stream, answers other than 200 are errors.
+func (c *Client) openStream(ctx context.Context, req Request) (*http.Response, error) {
+	req.Stream = true
+	if req.StreamOptions == nil {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	raw, err := c.stream.PostCtx(ctx, CompletionsPath, httpc.SetBody(req),
+		httpc.SetHeader("Accept", "text/event-stream"),
+		httpc.SetDoNotParseResponse())
+	if raw != nil && raw.RawBody() != nil && err != nil {
+		defer raw.RawBody().Close()
+		body, _ := io.ReadAll(io.LimitReader(raw.RawBody(), 64<<10))
+		return nil, apiError(raw, body, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return raw.RawResponse, nil
+}
+
+// apiError turns err into an *errorx.Error carrying the message of the
+// error body of the API, if any.
+func apiError(res *resty.Response, body []byte, err error) error {
+	var e *errorx.Error
+	if res == nil || !errors.As(err, &e) {
+		return err
+	}
+	var payload struct {
+		Error *APIError `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil || payload.Error == nil {
+		return err
+	}
+	return newAPIError(res.StatusCode(), payload.Error)
+}
+
+func newAPIError(status int, e *APIError) *errorx.Error {
+	bizType := "openai"
+	if e.Type != "" {
+		bizType += "-" + e.Type
+	}
+	return errorx.New(bizType, status, e.Message).
+		WithMetadata(errorx.Metadata{"type": e.Type, "param": e.Param, "code": e.Code})
+}
+
+// Stream reads the chunks of a streamed completion.
+type Stream struct {
+	body  io.Closer
+	r     *sse.Reader
+	acc   Accumulator
+	chunk *Chunk
+	err   error
+}
+
+// Next reads the next chunk, it returns false at the end of the stream or
+// on error, see Err.
+func (s *Stream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		e, err := s.r.Next()
+		if err != nil {
+			// Some servers close the stream without [DONE].
+			if errors.Is(err, io.EOF) && !s.finished() {
+				err = io.ErrUnexpectedEOF
+			}
+			s.err = err
+			return false
+		}
+		chunk, done, err := parseChunk(e.Data)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if done {
+			s.err = io.EOF
+			return false
+		}
+		if chunk == nil {
+			continue
+		}
+		s.acc.Add(chunk)
+		s.chunk = chunk
+		return true
+	}
+}
+
+// finished tells whether all the choices read have a finish reason.
+func (s *Stream) finished() bool {
+	choices := s.acc.res.Choices
+	for _, c := range choices {
+		if c.FinishReason == "" {
+			return false
+		}
+	}
+	return len(choices) > 0
+}
+
+// Chunk returns the chunk read by Next.
+func (s *Stream) Chunk() *Chunk {
+	return s.chunk
+}
+
+// Err returns the error which stopped Next, nil at the end of the stream.
+func (s *Stream) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+	return s.err
+}
+
+// Response returns the chunks read so far aggregated.
+func (s *Stream) Response() *Response {
+	return s.acc.Response()
+}
+
+// Close closes the stream, cancelling the completion if unfinished.
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
+
+// Collect reads the stream to its end and returns the aggregated response.
+func (s *Stream) Collect() (*Response, error) {
+	for s.Next() {
+	}
+	return s.Response(), s.Err()
+}
+
+// parseChunk parses the data of an event. It returns done at the end of the
+// stream, and a nil chunk for events to skip.
+func parseChunk(data string) (chunk *Chunk, done bool, err error) {
+	data = strings.TrimSpace(data)
+	if data == doneData {
+		return nil, true, nil
+	}
+	if data == "" {
+		return nil, false, nil
+	}
+	var payload struct {
+		Chunk
+		Error *APIError `json:"error"`
+	}
+	if err = json.Unmarshal([]byte(data), &payload); err != nil {
+		return nil, false, err
+	}
+	if payload.Error != nil {
+		return nil, false, newAPIError(http.StatusBadGateway, payload.Error)
+	}
+	return &payload.Chunk, false, nil
+}
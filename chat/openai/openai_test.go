@@ -0,0 +1,172 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leclerc04/go-tool/chat"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/stretchr/testify/assert"
+)
+
+var streamChunks = []string{
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"Let me "},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"check."},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"time","arguments":"{}"}}]},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":null}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+}
+
+var wantStreamed = &Response{
+	ID: "c1", Object: "chat.completion", Created: 1, Model: "m",
+	Choices: []Choice{{
+		Message: Message{
+			Role:    RoleAssistant,
+			Content: "Let me check.",
+			ToolCalls: []ToolCall{
+				{ID: "call_1", Type: "function", Function: FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_2", Type: "function", Function: FunctionCall{Name: "time", Arguments: "{}"}},
+			},
+		},
+		FinishReason: FinishToolCalls,
+	}},
+	Usage: &Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"error":{"message":"Invalid API key","type":"invalid_request_error","code":"invalid_api_key"}}`)
+			return
+		}
+		var req Request
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if !req.Stream {
+			assert.Nil(t, req.StreamOptions)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"id":"c0","object":"chat.completion","created":1,"model":"m",
+				"choices":[{"index":0,"message":{"role":"assistant","content":"Hi!"},"finish_reason":"stop"}],
+				"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+			return
+		}
+		assert.True(t, req.StreamOptions.IncludeUsage)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range streamChunks {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		if req.User != "no-done" {
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		}
+	}))
+}
+
+func TestCreateChatCompletion(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	res, err := NewClient(server.URL+"/v1/", "key").CreateChatCompletion(context.Background(), Request{
+		Model:    "m",
+		Messages: []Message{{Role: RoleUser, Content: "Hello"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi!", res.Choices[0].Message.Content)
+	assert.Equal(t, FinishStop, res.Choices[0].FinishReason)
+	assert.Equal(t, 4, res.Usage.TotalTokens)
+
+	_, err = NewClient(server.URL+"/v1", "wrong").CreateChatCompletion(context.Background(), Request{Model: "m"})
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, http.StatusUnauthorized, e.Code)
+	assert.Equal(t, "Invalid API key", e.Msg)
+	assert.Equal(t, "invalid_api_key", e.Metadata["code"])
+
+	_, err = NewClient(server.URL+"/v1", "wrong").CreateChatCompletionStream(context.Background(), Request{Model: "m"})
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, "Invalid API key", e.Msg)
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	for _, user := range []string{"", "no-done"} {
+		stream, err := NewClient(server.URL+"/v1", "key").CreateChatCompletionStream(context.Background(), Request{Model: "m", User: user})
+		if !assert.NoError(t, err) {
+			return
+		}
+		var content strings.Builder
+		for stream.Next() {
+			for _, c := range stream.Chunk().Choices {
+				content.WriteString(c.Delta.Content)
+			}
+		}
+		assert.NoError(t, stream.Err())
+		assert.NoError(t, stream.Close())
+		assert.Equal(t, "Let me check.", content.String())
+		assert.Equal(t, wantStreamed, stream.Response())
+	}
+}
+
+func TestStream_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", streamChunks[1])
+		_, _ = io.WriteString(w, `data: {"error":{"message":"overloaded","type":"server_error"}}`+"\n\n")
+	}))
+	defer server.Close()
+
+	stream, err := NewClient(server.URL, "").CreateChatCompletionStream(context.Background(), Request{Model: "m"})
+	assert.NoError(t, err)
+	defer stream.Close()
+	res, err := stream.Collect()
+	assert.EqualError(t, err, "overloaded")
+	assert.Equal(t, "Let me ", res.Choices[0].Message.Content)
+}
+
+func TestRelay(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	res, result := NewClient(server.URL+"/v1", "key").Relay(w, httptest.NewRequest(http.MethodPost, "/", nil),
+		Request{Model: "m"}, chat.StreamConf{Heartbeat: -1})
+	assert.Equal(t, chat.EndCompleted, result.Reason)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, wantStreamed, res)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, len(streamChunks)+1, strings.Count(w.Body.String(), "data: "))
+	assert.True(t, strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n"))
+}
+
+func TestRelay_Error(t *testing.T) {
+	errorEvent := `data: {"error":{"message":"overloaded","type":"server_error"}}` + "\n\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", streamChunks[1])
+		_, _ = io.WriteString(w, errorEvent)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "")
+	// Streams are only limited by their context and StreamConf.Timeout.
+	assert.Zero(t, c.stream.GetClient().Timeout)
+
+	w := httptest.NewRecorder()
+	res, result := c.Relay(w, httptest.NewRequest(http.MethodPost, "/", nil), Request{Model: "m"}, chat.StreamConf{Heartbeat: -1})
+	assert.Equal(t, chat.EndUpstreamError, result.Reason)
+	assert.EqualError(t, result.Err, "overloaded")
+	assert.Equal(t, "Let me ", res.Choices[0].Message.Content)
+	assert.True(t, strings.HasSuffix(w.Body.String(), errorEvent), w.Body.String())
+}
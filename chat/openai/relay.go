@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/leclerc04/go-tool/chat"
+	"github.com/leclerc04/go-tool/chat/sse"
+)
+
+// Relay streams the completion of req to w through chat.RelayStream, events
+// being forwarded as received, and returns the aggregated response. conf.Transform
+// is called on each event after it is aggregated. A stream sending an error
+// ends with chat.EndUpstreamError, once the error event is forwarded.
+func (c *Client) Relay(w http.ResponseWriter, r *http.Request, req Request, conf chat.StreamConf) (*Response, chat.StreamResult) {
+	var acc Accumulator
+	transform := conf.Transform
+	conf.Transform = func(e *sse.Event) (bool, error) {
+		chunk, _, err := parseChunk(e.Data)
+		if err != nil {
+			// The client is told why the stream ends.
+			return true, err
+		}
+		if chunk != nil {
+			acc.Add(chunk)
+		}
+		if transform == nil {
+			return true, nil
+		}
+		return transform(e)
+	}
+	res := chat.RelayStream(w, r, func(ctx context.Context) (*http.Response, error) {
+		return c.openStream(ctx, req)
+	}, conf)
+	return acc.Response(), res
+}
@@ -0,0 +1,224 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/chat/sse"
+	jsonx "github.com/leclerc04/go-tool/jsonc"
+	"github.com/zeromicro/go-zero/core/logc"
+)
+
+var metricStreamsTotal = mon.NewCounterVec(
+	"chat", "streams_total", "Number of streams relayed, by why they ended.",
+	[]string{"reason"})
+
+// DefaultHeartbeat is the interval of heartbeats when StreamConf.Heartbeat is zero.
+const DefaultHeartbeat = 15 * time.Second
+
+// EndReason tells why a stream ended.
+type EndReason string
+
+const (
+	EndCompleted     EndReason = "completed"
+	EndClientGone    EndReason = "client_gone"
+	EndUpstreamError EndReason = "upstream_error"
+	EndTimeout       EndReason = "timeout"
+)
+
+var (
+	errClientGone  = errors.New("chat: client went away")
+	errIdleTimeout = errors.New("chat: no event from the model within the idle timeout")
+	errTimeout     = errors.New("chat: stream exceeded its timeout")
+)
+
+// StreamConf configures StreamChat.
+type StreamConf struct {
+	// Heartbeat is the interval of the SSE comments sent to keep proxies
+	// from closing the connection, DefaultHeartbeat if zero. Negative
+	// disables heartbeats.
+	Heartbeat time.Duration
+	// IdleTimeout, if set, ends the stream when the model sends no event
+	// for that long.
+	IdleTimeout time.Duration
+	// Timeout, if set, limits the whole stream.
+	Timeout time.Duration
+	// Transform, if set, changes or drops events before they are relayed.
+	Transform sse.TransformFunc
+}
+
+// StreamResult is the outcome of StreamChat.
+type StreamResult struct {
+	Answer Answer
+	Reason EndReason
+	// Err is why the stream didn't complete.
+	Err error
+}
+
+// StreamChat relays the stream of the model at reqURL to w. The request to
+// the model is cancelled as soon as the client goes away, which is noticed
+// through the context of r or a failed write.
+func StreamChat(w http.ResponseWriter, r *http.Request, reqURL string, data any, conf StreamConf) StreamResult {
+	return streamChat(r.Context(), w, reqURL, data, conf)
+}
+
+func streamChat(ctx context.Context, w http.ResponseWriter, reqURL string, data any, conf StreamConf) StreamResult {
+	if reqURL == "" {
+		return StreamResult{Reason: EndUpstreamError, Err: errors.New("url is empty")}
+	}
+	if _, err := url.Parse(reqURL); err != nil {
+		return StreamResult{Reason: EndUpstreamError, Err: err}
+	}
+
+	// The last event is the final answer.
+	var lastAns string
+	transform := conf.Transform
+	conf.Transform = func(e *sse.Event) (bool, error) {
+		lastAns = e.Data
+		if transform == nil {
+			return true, nil
+		}
+		return transform(e)
+	}
+	res := relayStream(ctx, w, func(ctx context.Context) (*http.Response, error) {
+		return SentHttpReqToModel(ctx, reqURL, data)
+	}, conf)
+	if res.Reason != EndCompleted {
+		return res
+	}
+	if err := jsonx.Unmarshal([]byte(lastAns), &res.Answer); err != nil {
+		return StreamResult{Reason: EndUpstreamError, Err: err}
+	}
+	return res
+}
+
+// OpenFunc sends the request of a stream.
+type OpenFunc func(ctx context.Context) (*http.Response, error)
+
+// RelayStream relays the server-sent events answered to the request of open,
+// with the cancellation, heartbeats and timeouts of StreamChat. Responses
+// other than 200 end the stream with EndUpstreamError.
+func RelayStream(w http.ResponseWriter, r *http.Request, open OpenFunc, conf StreamConf) StreamResult {
+	return relayStream(r.Context(), w, open, conf)
+}
+
+func relayStream(ctx context.Context, w http.ResponseWriter, open OpenFunc, conf StreamConf) StreamResult {
+	res := relay(ctx, w, open, conf)
+	metricStreamsTotal.With(mon.Labels{"reason": string(res.Reason)}).Inc()
+	if res.Err != nil {
+		logc.Infow(ctx, "chat stream ended",
+			logc.Field("reason", res.Reason),
+			logc.Field("err", res.Err))
+	}
+	return res
+}
+
+func relay(parent context.Context, w http.ResponseWriter, open OpenFunc, conf StreamConf) StreamResult {
+	if _, ok := w.(http.Flusher); !ok {
+		return StreamResult{Reason: EndUpstreamError, Err: errors.New("not support http flusher")}
+	}
+
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	if conf.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, conf.Timeout, errTimeout)
+		defer cancelTimeout()
+	}
+	// end explains an error by the reason the context was cancelled, if any.
+	end := func(err error) StreamResult {
+		switch cause := context.Cause(ctx); {
+		case parent.Err() != nil || errors.Is(cause, errClientGone):
+			return StreamResult{Reason: EndClientGone, Err: errClientGone}
+		case errors.Is(cause, errIdleTimeout) || errors.Is(cause, errTimeout):
+			return StreamResult{Reason: EndTimeout, Err: cause}
+		}
+		return StreamResult{Reason: EndUpstreamError, Err: err}
+	}
+
+	var idle *time.Timer
+	if conf.IdleTimeout > 0 {
+		// Waiting for the response headers counts as idle too.
+		idle = time.AfterFunc(conf.IdleTimeout, func() { cancel(errIdleTimeout) })
+		defer idle.Stop()
+	}
+
+	upstream, err := open(ctx)
+	if err != nil {
+		return end(err)
+	}
+	defer upstream.Body.Close()
+	if upstream.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(upstream.Body, 1024))
+		return end(fmt.Errorf("model answered %d: %s", upstream.StatusCode, body))
+	}
+
+	SetSSEHeader(w)
+	writer := sse.NewWriter(w)
+	stop := startHeartbeat(writer, conf.Heartbeat, func() { cancel(errClientGone) })
+	defer stop()
+
+	err = sse.Relay(writer, sse.NewReader(upstream.Body), func(e *sse.Event) (bool, error) {
+		if idle != nil {
+			idle.Reset(conf.IdleTimeout)
+		}
+		if conf.Transform == nil {
+			return true, nil
+		}
+		return conf.Transform(e)
+	})
+	if err != nil {
+		var writeErr *sse.WriteError
+		if errors.As(err, &writeErr) {
+			cancel(errClientGone)
+		}
+		return end(err)
+	}
+	if ctx.Err() != nil {
+		// The body ended because the request was cancelled.
+		return end(ctx.Err())
+	}
+	return StreamResult{Reason: EndCompleted}
+}
+
+// startHeartbeat writes a comment every interval until stop is called.
+// onError is called if a write fails.
+func startHeartbeat(w *sse.Writer, interval time.Duration, onError func()) (stop func()) {
+	if interval == 0 {
+		interval = DefaultHeartbeat
+	}
+	if interval < 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.WriteComment("ping"); err != nil {
+					onError()
+					return
+				}
+			}
+		}
+	}()
+	// The response must not be written once the handler returns.
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const finalFrame = `data: {"code": 200, "response": "hello", "tokens": 2}` + "\n\n"
+
+func TestStreamChat_Heartbeat(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetSSEHeader(w)
+		_, _ = fmt.Fprint(w, "data: hel\n\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = fmt.Fprint(w, finalFrame)
+	}))
+	defer model.Close()
+
+	w := httptest.NewRecorder()
+	res := StreamChat(w, httptest.NewRequest(http.MethodGet, "/", nil), model.URL, nil,
+		StreamConf{Heartbeat: 20 * time.Millisecond})
+	assert.Equal(t, EndCompleted, res.Reason)
+	assert.NoError(t, res.Err)
+	assert.Equal(t, "hello", res.Answer.Response)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "data: hel\n\n"))
+	assert.Contains(t, w.Body.String(), ": ping\n\n")
+}
+
+func TestStreamChat_ClientGone(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetSSEHeader(w)
+		for i := 0; ; i++ {
+			_, _ = fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				close(upstreamCancelled)
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer model.Close()
+
+	results := make(chan StreamResult, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results <- StreamChat(w, r, model.URL, nil, StreamConf{Heartbeat: -1})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	assert.Equal(t, "data: 0\n", line)
+	cancel()
+	_ = resp.Body.Close()
+
+	select {
+	case res := <-results:
+		assert.Equal(t, EndClientGone, res.Reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not ended")
+	}
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream not cancelled")
+	}
+}
+
+func TestStreamChat_Timeouts(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetSSEHeader(w)
+		_, _ = fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer model.Close()
+
+	for _, conf := range []StreamConf{{IdleTimeout: 50 * time.Millisecond}, {Timeout: 50 * time.Millisecond}} {
+		start := time.Now()
+		res := StreamChat(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), model.URL, nil, conf)
+		assert.Equal(t, EndTimeout, res.Reason)
+		assert.Error(t, res.Err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	}
+}
+
+func TestStreamChat_UpstreamError(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer model.Close()
+
+	w := httptest.NewRecorder()
+	res := StreamChat(w, httptest.NewRequest(http.MethodGet, "/", nil), model.URL, nil, StreamConf{})
+	assert.Equal(t, EndUpstreamError, res.Reason)
+	assert.ErrorContains(t, res.Err, "overloaded")
+	assert.Empty(t, w.Body.String())
+}
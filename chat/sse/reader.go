@@ -0,0 +1,179 @@
+// Package sse reads and writes server-sent event streams, see
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxEventSize is the default size limit of an event read by Reader.
+const DefaultMaxEventSize = 1 << 20
+
+// ErrEventTooLarge is returned by Reader for events over its MaxEventSize.
+var ErrEventTooLarge = errors.New("sse: event too large")
+
+// Event is an event of a stream.
+type Event struct {
+	// ID is the id field of the event, empty if it has none. The ID of the
+	// stream, which carries over to the next events, is Reader.LastEventID.
+	ID string
+	// Event is the type of the event, "message" if empty.
+	Event string
+	// Data is the payload, lines are separated by "\n".
+	Data string
+	// Retry is the reconnection time hint, zero if not set.
+	Retry time.Duration
+	// Comment is set on comments, which are returned by Reader only if
+	// Reader.Comments is true. Other fields are empty then.
+	Comment string
+}
+
+// Reader parses events from a stream.
+type Reader struct {
+	// Comments makes Next return comments, like heartbeats, as events.
+	Comments bool
+	// MaxEventSize limits the size of the lines of an event, it is
+	// DefaultMaxEventSize if zero.
+	MaxEventSize int
+
+	r           *bufio.Reader
+	lastEventID string
+	started     bool
+	// afterCR tells the last line ended with "\r", a "\n" following it
+	// belongs to the same line end. It isn't peeked at, which would block
+	// until the next event on streams ending lines with "\r".
+	afterCR bool
+
+	// The event being read, kept across the comments returned by Next.
+	event   Event
+	data    strings.Builder
+	hasData bool // hasData tells whether a data field was read.
+	size    int
+}
+
+// NewReader creates a reader of r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// LastEventID returns the ID of the last event, to send in the Last-Event-ID
+// header when reconnecting.
+func (r *Reader) LastEventID() string {
+	return r.lastEventID
+}
+
+// Next returns the next event. It returns io.EOF at the end of the stream,
+// dropping an incomplete last event as browsers do.
+func (r *Reader) Next() (Event, error) {
+	maxSize := r.MaxEventSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxEventSize
+	}
+
+	for {
+		line, err := r.readLine(maxSize - r.size)
+		if err != nil {
+			r.reset()
+			return Event{}, err
+		}
+		r.size += len(line)
+
+		if len(line) == 0 {
+			if !r.hasData {
+				// Nothing to dispatch, e.g. only an id or a retry.
+				r.event, r.size = Event{Retry: r.event.Retry}, 0
+				continue
+			}
+			e := r.event
+			e.Data = r.data.String()
+			r.reset()
+			return e, nil
+		}
+		if line[0] == ':' {
+			if r.Comments {
+				return Event{Comment: strings.TrimPrefix(string(line[1:]), " ")}, nil
+			}
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+		switch string(field) {
+		case "event":
+			r.event.Event = string(value)
+		case "data":
+			if r.hasData {
+				r.data.WriteByte('\n')
+			}
+			r.data.Write(value)
+			r.hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				r.event.ID = string(value)
+				r.lastEventID = r.event.ID
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				r.event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// reset drops the event being read.
+func (r *Reader) reset() {
+	r.event = Event{}
+	r.data.Reset()
+	r.hasData = false
+	r.size = 0
+}
+
+// readLine returns a line without its end, which is "\r\n", "\n" or "\r".
+func (r *Reader) readLine(max int) ([]byte, error) {
+	if !r.started {
+		r.started = true
+		// A leading BOM is ignored. Only a stream starting like one is peeked
+		// further, so that a short first line isn't held back.
+		if b, err := r.r.Peek(1); err == nil && b[0] == 0xEF {
+			if bom, err := r.r.Peek(3); err == nil && string(bom) == "\xEF\xBB\xBF" {
+				_, _ = r.r.Discard(3)
+			}
+		}
+	}
+
+	var line []byte
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		if r.afterCR {
+			r.afterCR = false
+			if b == '\n' {
+				continue
+			}
+		}
+		switch b {
+		case '\n':
+			return line, nil
+		case '\r':
+			r.afterCR = true
+			return line, nil
+		}
+		if len(line) >= max {
+			return nil, ErrEventTooLarge
+		}
+		line = append(line, b)
+	}
+}
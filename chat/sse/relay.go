@@ -0,0 +1,60 @@
+package sse
+
+import (
+	"errors"
+	"io"
+)
+
+// TransformFunc changes an event before it is relayed, it returns false to
+// drop the event. An error stops the relay, once the event is relayed if the
+// transform returned true too, e.g. to forward an error event.
+type TransformFunc func(e *Event) (bool, error)
+
+// WriteError is returned by Relay when writing to the client fails, as
+// opposed to reading the source.
+type WriteError struct {
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return "sse: write: " + e.Err.Error()
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// Relay forwards the events of r to w until the end of r, applying transform
+// if not nil. Comments are forwarded if r.Comments is set. Failed writes are
+// returned as *WriteError.
+func Relay(w *Writer, r *Reader, transform TransformFunc) error {
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var transformErr error
+		if transform != nil {
+			var ok bool
+			ok, transformErr = transform(&e)
+			if !ok {
+				if transformErr != nil {
+					return transformErr
+				}
+				continue
+			}
+		}
+		if err = w.Write(e); err != nil {
+			if errors.Is(err, ErrInvalidField) {
+				return err
+			}
+			return &WriteError{Err: err}
+		}
+		if transformErr != nil {
+			return transformErr
+		}
+	}
+}
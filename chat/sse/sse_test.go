@@ -0,0 +1,132 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, r *Reader) []Event {
+	var events []Event
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		if !assert.NoError(t, err) {
+			return events
+		}
+		events = append(events, e)
+	}
+}
+
+func TestReader(t *testing.T) {
+	stream := "\xEF\xBB\xBFdata: first\r\n\r\n" +
+		": ping - 2024-01-01 00:00:00.000000\n" +
+		"event: delta\nid: 1\ndata:{\"a\":\ndata: 1}\n\n" +
+		"retry: 3000\n\n" +
+		"id\rdata\r\r" +
+		"event: ignored\n\n" +
+		"data: a: b\n\n" +
+		"data: incomplete"
+
+	r := NewReader(strings.NewReader(stream))
+	assert.Equal(t, []Event{
+		{Data: "first"},
+		{ID: "1", Event: "delta", Data: "{\"a\":\n1}"},
+		{Data: "", Retry: 3 * time.Second},
+		{Data: "a: b"},
+	}, readAll(t, r))
+	assert.Equal(t, "", r.LastEventID())
+
+	r = NewReader(strings.NewReader(": ping\n\ndata: x\n\n"))
+	r.Comments = true
+	assert.Equal(t, []Event{{Comment: "ping"}, {Data: "x"}}, readAll(t, r))
+
+	// A comment inside an event doesn't drop its data.
+	r = NewReader(strings.NewReader("data: a\n: ping\ndata: b\n\n"))
+	r.Comments = true
+	assert.Equal(t, []Event{{Comment: "ping"}, {Data: "a\nb"}}, readAll(t, r))
+}
+
+func TestReader_LiveCR(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		_, _ = io.WriteString(pw, "data: a\r\r")
+	}()
+
+	// The event is returned without waiting for the next byte.
+	done := make(chan Event)
+	go func() {
+		e, _ := NewReader(pr).Next()
+		done <- e
+	}()
+	select {
+	case e := <-done:
+		assert.Equal(t, Event{Data: "a"}, e)
+	case <-time.After(time.Second):
+		t.Fatal("event held back until the next byte")
+	}
+}
+
+func TestReader_MaxEventSize(t *testing.T) {
+	r := NewReader(strings.NewReader("data: " + strings.Repeat("x", 100) + "\n\n"))
+	r.MaxEventSize = 50
+	_, err := r.Next()
+	assert.ErrorIs(t, err, ErrEventTooLarge)
+}
+
+func TestWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewWriter(rec)
+	assert.NoError(t, w.Write(Event{Event: "delta", ID: "7", Retry: time.Second, Data: "a\nb\r\nc"}))
+	assert.NoError(t, w.WriteComment("ping"))
+	assert.NoError(t, w.Write(Event{Data: "x", Comment: "note"}))
+	assert.ErrorIs(t, w.Write(Event{Event: "a\nb"}), ErrInvalidField)
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "event: delta\nid: 7\nretry: 1000\ndata: a\ndata: b\ndata: c\n\n"+
+		": ping\n\n"+
+		": note\ndata: x\n\n", rec.Body.String())
+
+	// What is written reads back the same.
+	r := NewReader(strings.NewReader(rec.Body.String()))
+	assert.Equal(t, []Event{
+		{ID: "7", Event: "delta", Retry: time.Second, Data: "a\nb\nc"},
+		{Data: "x"},
+	}, readAll(t, r))
+	assert.Equal(t, "7", r.LastEventID())
+}
+
+func TestRelay(t *testing.T) {
+	src := NewReader(strings.NewReader("data: hello\n\n: ping\n\nevent: usage\ndata: 3\n\ndata: world\n\n"))
+	var out strings.Builder
+	err := Relay(NewWriter(&out), src, func(e *Event) (bool, error) {
+		if e.Event == "usage" {
+			return false, nil
+		}
+		e.Data = strings.ToUpper(e.Data)
+		return true, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "data: HELLO\n\ndata: WORLD\n\n", out.String())
+}
+
+func TestRelay_ForwardError(t *testing.T) {
+	src := NewReader(strings.NewReader("data: hello\n\ndata: error\n\ndata: world\n\n"))
+	var out strings.Builder
+	boom := errors.New("boom")
+	err := Relay(NewWriter(&out), src, func(e *Event) (bool, error) {
+		if e.Data == "error" {
+			return true, boom
+		}
+		return true, nil
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, "data: hello\n\ndata: error\n\n", out.String())
+}
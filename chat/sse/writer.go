@@ -0,0 +1,93 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidField is returned by Writer for ids and event types containing
+// line breaks, which would corrupt the stream.
+var ErrInvalidField = errors.New("sse: line break in id or event")
+
+// Writer writes events to a stream. It is safe for concurrent use, e.g. to
+// send heartbeats while relaying events.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+	f  http.Flusher
+}
+
+// NewWriter creates a writer of w, flushed after each event if it is an
+// http.Flusher.
+func NewWriter(w io.Writer) *Writer {
+	f, _ := w.(http.Flusher)
+	return &Writer{w: w, f: f}
+}
+
+// Write writes e. Its Comment, if set, is written as a comment before the
+// fields, data lines are split on "\n", "\r\n" and "\r".
+func (w *Writer) Write(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return ErrInvalidField
+	}
+
+	var b strings.Builder
+	if e.Comment != "" {
+		writeComment(&b, e.Comment)
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	// An event without data isn't dispatched, a comment alone is just a
+	// comment.
+	if e.Data != "" || e.Event != "" || e.ID != "" || e.Comment == "" {
+		for _, line := range splitLines(e.Data) {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteByte('\n')
+	return w.write(b.String())
+}
+
+// WriteComment writes a comment, ignored by clients, e.g. as a heartbeat
+// keeping proxies from closing an idle connection.
+func (w *Writer) WriteComment(text string) error {
+	var b strings.Builder
+	writeComment(&b, text)
+	b.WriteByte('\n')
+	return w.write(b.String())
+}
+
+func (w *Writer) write(s string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := io.WriteString(w.w, s); err != nil {
+		return err
+	}
+	if w.f != nil {
+		w.f.Flush()
+	}
+	return nil
+}
+
+func writeComment(b *strings.Builder, text string) {
+	for _, line := range splitLines(text) {
+		b.WriteString(": " + line + "\n")
+	}
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
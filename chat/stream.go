@@ -1,16 +1,12 @@
 package chat
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"regexp"
 
+	"github.com/leclerc04/go-tool/chat/sse"
 	jsonx "github.com/leclerc04/go-tool/jsonc"
 )
 
@@ -20,23 +16,17 @@ type Answer struct {
 	Tokens   int64  `json:"tokens"`
 }
 
+// CreateStreamChat 将模型的流式回答转发给客户端, 客户端断开时取消模型请求, 并定时发送心跳.
+// 需要超时控制或结束原因时使用 StreamChat
 func CreateStreamChat(ctx context.Context, w http.ResponseWriter, reqURL string, data any) (answer Answer, err error) {
-	if reqURL == "" {
-		return Answer{}, errors.New("url is empty")
+	res := streamChat(ctx, w, reqURL, data, StreamConf{})
+	if res.Err != nil {
+		return Answer{}, res.Err
 	}
-
-	if _, err = url.Parse(reqURL); err != nil {
-		return
-	}
-
-	llmResp, err := SentHttpReqToModel(ctx, reqURL, data)
-	if err != nil {
-		return
+	if res.Answer.Code != http.StatusOK {
+		return Answer{}, nil
 	}
-	defer llmResp.Body.Close()
-	SetSSEHeader(w)
-
-	return SentModelSSEResp(w, llmResp)
+	return res.Answer, nil
 }
 
 func SentHttpReqToModel(ctx context.Context, reqURL string, requestBody any) (resp *http.Response, err error) {
@@ -60,49 +50,32 @@ func SentHttpReqToModel(ctx context.Context, reqURL string, requestBody any) (re
 	return
 }
 
+// SentModelSSEResp 将模型的 SSE 流转发给客户端, 保留事件名、id、retry 与多行数据, 心跳注释不转发.
+// 最后一个事件的数据为最终的 Answer
 func SentModelSSEResp(w http.ResponseWriter, sseResp *http.Response) (answer Answer, err error) {
-	reader := bufio.NewReader(sseResp.Body)
-	headerData := []byte("data: ")
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	return SentModelSSERespWith(w, sseResp, nil)
+}
+
+// SentModelSSERespWith 与 SentModelSSEResp 相同, 但转发前用 transform 修改或丢弃每个事件
+func SentModelSSERespWith(w http.ResponseWriter, sseResp *http.Response, transform sse.TransformFunc) (answer Answer, err error) {
+	if _, ok := w.(http.Flusher); !ok {
 		return Answer{}, errors.New("not support http flusher")
 	}
 
-	var lastAns []byte
-	for {
-		var line []byte
-		if line, err = reader.ReadBytes('\n'); err != nil {
-			if errors.Is(err, io.EOF) {
-				err = nil
-				break
-			}
-			return
-		}
-
-		line = bytes.TrimSpace(line)
-		line = bytes.TrimPrefix(line, headerData)
-
-		strData := string(line)
-		if strData == "" || strData == "\n" || strData == "\r" || strData == "data:" {
-			continue
-		}
-		lastAns = line
-
-		regexStr := "^: ping - \\d{4}-\\d{2}-\\d{2} \\d{2}:\\d{2}:\\d{2}\\.\\d{6}$"
-		regex := regexp.MustCompile(regexStr)
-		if isMatch := regex.MatchString(strData); isMatch {
-			continue
-		}
-		_, err = fmt.Fprintf(w, "data: %v\n\n", strData)
-		if err != nil {
-			return
+	var lastAns string
+	err = sse.Relay(sse.NewWriter(w), sse.NewReader(sseResp.Body), func(e *sse.Event) (bool, error) {
+		lastAns = e.Data
+		if transform == nil {
+			return true, nil
 		}
-
-		flusher.Flush()
+		return transform(e)
+	})
+	if err != nil {
+		return
 	}
 
 	var answerDTO Answer
-	if err = jsonx.Unmarshal(lastAns, &answerDTO); err != nil {
+	if err = jsonx.Unmarshal([]byte(lastAns), &answerDTO); err != nil {
 		return
 	}
 
@@ -113,11 +86,18 @@ func SentModelSSEResp(w http.ResponseWriter, sseResp *http.Response) (answer Ans
 	return answerDTO, nil
 }
 
+// SSEAllowAnyOrigin 为 true 时 SetSSEHeader 沿用旧行为, 允许任意来源跨域访问.
+// 使用 middleware.CORS 按策略处理跨域的服务应在启动时将其设为 false, 否则被拒绝的来源也会得到 "*"
+var SSEAllowAnyOrigin = true
+
+// SetSSEHeader 设置 SSE 响应头. 见 SSEAllowAnyOrigin, 已设置的 Access-Control-Allow-Origin 不会被覆盖
 func SetSSEHeader(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if SSEAllowAnyOrigin && w.Header().Get("Access-Control-Allow-Origin") == "" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
 	// 如果服务器和客户端之间有任何代理, 那将建议设置响应头 X-Accel-Buffering 为 no。
 	w.Header().Set("X-Accel-Buffering", "no")
 	// 在第一次渲染调用之前必须先行设置状态代码和响应头文件
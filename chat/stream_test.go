@@ -3,8 +3,10 @@ package chat
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,3 +54,35 @@ func TestCreateStreamChat_WithError(t *testing.T) {
 	assert.Empty(t, answer)
 	assert.NotNil(t, err)
 }
+
+func TestSentModelSSEResp(t *testing.T) {
+	upstream := "event: delta\nid: 1\ndata: line1\ndata: line2\n\n" +
+		": ping - 2024-01-01 00:00:00.000000\n\n" +
+		"data: {\"code\": 200, \"response\": \"hi\", \"tokens\": 3}\n\n"
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(upstream))}
+
+	w := httptest.NewRecorder()
+	answer, err := SentModelSSEResp(w, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, Answer{Code: 200, Response: "hi", Tokens: 3}, answer)
+	assert.Equal(t, "event: delta\nid: 1\ndata: line1\ndata: line2\n\n"+
+		"data: {\"code\": 200, \"response\": \"hi\", \"tokens\": 3}\n\n", w.Body.String())
+}
+
+func TestSetSSEHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetSSEHeader(w)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// An origin allowed by middleware.CORS is kept.
+	w = httptest.NewRecorder()
+	w.Header().Set("Access-Control-Allow-Origin", "https://example.com")
+	SetSSEHeader(w)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	defer func() { SSEAllowAnyOrigin = true }()
+	SSEAllowAnyOrigin = false
+	w = httptest.NewRecorder()
+	SetSSEHeader(w)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
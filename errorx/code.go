@@ -9,3 +9,17 @@ const (
 	CodeInvalidToken  ResCode = 403
 	CodeInternalErr   ResCode = 500
 )
+
+// 字段校验错误, 文案参数: {field}, 以及 {min} {max} {values}
+const (
+	CodeFieldRequired ResCode = 400001
+	CodeFieldType     ResCode = 400002
+	CodeFieldTooShort ResCode = 400003
+	CodeFieldTooLong  ResCode = 400004
+	CodeFieldTooSmall ResCode = 400005
+	CodeFieldTooLarge ResCode = 400006
+	CodeFieldPattern  ResCode = 400007
+	CodeFieldEnum     ResCode = 400008
+	CodeFieldPhone    ResCode = 400009
+	CodeFieldEmail    ResCode = 400010
+)
@@ -7,12 +7,13 @@ import (
 )
 
 type Error struct {
-	BizType  string   `json:"biz_type"` // 业务类型
-	Code     int      `json:"code"`     // 错误码
-	Msg      string   `json:"msg"`      // 错误信息
-	Metadata Metadata // 元数据
-	IsShow   bool     // 是否需要展示给用户
-	Err      error    // 原始错误
+	BizType     string   `json:"biz_type"` // 业务类型
+	Code        int      `json:"code"`     // 错误码
+	Msg         string   `json:"msg"`      // 错误信息
+	Metadata    Metadata // 元数据
+	IsShow      bool     // 是否需要展示给用户
+	IsLocalized bool     // 是否按请求语言展示 Code 的文案, Metadata 为文案参数
+	Err         error    // 原始错误
 }
 
 type Metadata map[string]any
@@ -98,6 +99,14 @@ func (e *Error) Show() *Error {
 	return e
 }
 
+// Localize 展示时按请求语言从文案目录取 Code 对应的文案, 取不到时仍展示 Msg.
+// 文案目录默认为 Catalog, 其他目录的 Code 需设置 httpc.RespConf.Catalog
+// eg: New("user-service-Register", int(CodeFieldRequired), CodeFieldRequired.Msg()).WithMetadata(Metadata{"field": "phone"}).Localize().Show()
+func (e *Error) Localize() *Error {
+	e.IsLocalized = true
+	return e
+}
+
 func IsNotFound(err error) bool {
 	if err == nil {
 		return false
@@ -1,22 +1,28 @@
 package errorx
 
-// codeMsgMap 预设错误, 业务错误请在业务代码中定义
-var codeMsgMap map[ResCode]string
+import (
+	"embed"
 
-func init() {
-	codeMsgMap = map[ResCode]string{
-		CodeSuccess:       "操作成功",
-		CodeInvalidParams: "请求参数错误",
-		CodeUnauthorized:  "请先登陆",
-		CodeInvalidToken:  "无效的Token",
-		CodeInternalErr:   "服务繁忙，请稍后再试",
-	}
-}
+	"github.com/leclerc04/go-tool/agl/util/i18n"
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
 
+// Catalog 预设错误的多语言文案, 业务错误请在业务代码中定义. 默认中文
+var Catalog = i18n.NewCatalog(language.Chinese).MustLoad(locales, "locales/*.json")
+
+// Msg 返回默认语言(中文)的文案
 func (code ResCode) Msg() string {
-	msg, ok := codeMsgMap[code]
-	if !ok {
-		msg = codeMsgMap[CodeInternalErr]
+	return code.Localize(nil, nil)
+}
+
+// Localize 返回 langs 中第一个有文案的语言的文案, 并用 params 替换其中的 {name} 参数
+func (code ResCode) Localize(langs []language.Tag, params map[string]any) string {
+	if msg, ok := Catalog.Message(langs, int(code), params); ok {
+		return msg
 	}
+	msg, _ := Catalog.Message(langs, int(CodeInternalErr), nil)
 	return msg
 }
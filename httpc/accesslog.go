@@ -0,0 +1,247 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	encrypt "github.com/leclerc04/go-tool/encryt"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/zeromicro/go-zero/core/logc"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DefaultMaxLogBodySize is the number of body bytes kept by the access log.
+const DefaultMaxLogBodySize = 2048
+
+// redactedValue replaces secrets in the access log.
+const redactedValue = "***"
+
+var (
+	// DefaultRedactedHeaders are never written to the access log.
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	// DefaultRedactedQuery are query and form parameters never written to the access log.
+	DefaultRedactedQuery = []string{"access_token", "refresh_token", "client_secret", "token", "sign", "signature", "password"}
+	// DefaultSecretFields are JSON fields replaced as a whole in the access log.
+	DefaultSecretFields = []string{"password", "access_token", "refresh_token", "client_secret", "token", "secret"}
+	// DefaultMaskedFields are JSON fields partially hidden with encrypt.HideStar.
+	DefaultMaskedFields = []string{"phone", "mobile", "email", "id_card"}
+)
+
+// AccessLogConf configures an AccessLog. The redacted names extend the defaults
+// and are matched case-insensitively.
+type AccessLogConf struct {
+	// MaxBodySize truncates logged bodies, DefaultMaxLogBodySize if zero,
+	// negative to omit bodies.
+	MaxBodySize   int
+	RedactHeaders []string
+	RedactQuery   []string
+	// SecretFields are JSON fields replaced as a whole, at any depth.
+	SecretFields []string
+	// MaskedFields are JSON fields partially hidden, at any depth.
+	MaskedFields []string
+	// Mask hides the values of MaskedFields, encrypt.HideStar if nil.
+	Mask func(string) string
+}
+
+// AccessLog logs every call of a Client through logc, with tokens and
+// personal data redacted.
+type AccessLog struct {
+	conf    AccessLogConf
+	headers sets.Set[string]
+	query   sets.Set[string]
+	secrets sets.Set[string]
+	masked  sets.Set[string]
+}
+
+// NewAccessLog creates an AccessLog.
+func NewAccessLog(conf AccessLogConf) *AccessLog {
+	if conf.MaxBodySize == 0 {
+		conf.MaxBodySize = DefaultMaxLogBodySize
+	}
+	if conf.Mask == nil {
+		conf.Mask = encrypt.HideStar
+	}
+	return &AccessLog{
+		conf:    conf,
+		headers: lowerSet(DefaultRedactedHeaders, conf.RedactHeaders),
+		query:   lowerSet(DefaultRedactedQuery, conf.RedactQuery),
+		secrets: lowerSet(DefaultSecretFields, conf.SecretFields),
+		masked:  lowerSet(DefaultMaskedFields, conf.MaskedFields),
+	}
+}
+
+func lowerSet(lists ...[]string) sets.Set[string] {
+	s := sets.New[string]()
+	for _, l := range lists {
+		for _, v := range l {
+			s.Insert(strings.ToLower(v))
+		}
+	}
+	return s
+}
+
+// log writes one line for a call of RequestCtx, r is its last attempt.
+func (l *AccessLog) log(ctx context.Context, r *resty.Request, res *resty.Response, err error, duration time.Duration, retries int) {
+	rawURL, header := r.URL, r.Header
+	if r.RawRequest != nil {
+		rawURL, header = r.RawRequest.URL.String(), r.RawRequest.Header
+	}
+	fields := []logc.LogField{
+		logc.Field("method", r.Method),
+		logc.Field("url", l.redactURL(rawURL)),
+		logc.Field("duration", duration.String()),
+		logc.Field("retries", retries),
+		logc.Field("req_headers", l.redactHeader(header)),
+	}
+	if l.conf.MaxBodySize > 0 {
+		fields = append(fields, logc.Field("req_body", l.requestBody(r)))
+	}
+	if res != nil && res.StatusCode() != 0 {
+		fields = append(fields,
+			logc.Field("status", res.StatusCode()),
+			logc.Field("resp_headers", l.redactHeader(res.Header())))
+		if l.conf.MaxBodySize > 0 {
+			fields = append(fields, logc.Field("resp_body", l.body(res.Header().Get("Content-Type"), res.Body())))
+		}
+	}
+	if err != nil {
+		fields = append(fields, logc.Field("err", l.errorText(res, err)))
+		logc.Errorw(ctx, "http access", fields...)
+		return
+	}
+	logc.Infow(ctx, "http access", fields...)
+}
+
+// errorText describes err without the response body it may carry, which is
+// already logged redacted.
+func (l *AccessLog) errorText(res *resty.Response, err error) string {
+	if res == nil || res.StatusCode() == 0 {
+		return err.Error()
+	}
+	var e *errorx.Error
+	if errors.As(err, &e) {
+		return e.BizType
+	}
+	if l.conf.MaxBodySize > 0 {
+		return l.body("", []byte(err.Error()))
+	}
+	return fmt.Sprintf("[%T]", err)
+}
+
+func (l *AccessLog) redactHeader(h http.Header) map[string]string {
+	m := make(map[string]string, len(h))
+	for k, v := range h {
+		if l.headers.Has(strings.ToLower(k)) {
+			m[k] = redactedValue
+			continue
+		}
+		m[k] = strings.Join(v, ", ")
+	}
+	return m
+}
+
+func (l *AccessLog) redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	u.RawQuery = l.redactValues(u.Query()).Encode()
+	return u.String()
+}
+
+func (l *AccessLog) redactValues(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for k, vs := range values {
+		switch key := strings.ToLower(k); {
+		case l.query.Has(key) || l.secrets.Has(key):
+			redacted[k] = []string{redactedValue}
+		case l.masked.Has(key):
+			for _, v := range vs {
+				redacted.Add(k, l.conf.Mask(v))
+			}
+		default:
+			redacted[k] = vs
+		}
+	}
+	return redacted
+}
+
+func (l *AccessLog) requestBody(r *resty.Request) string {
+	switch body := r.Body.(type) {
+	case nil:
+		if len(r.FormData) > 0 {
+			return l.truncate([]byte(l.redactValues(r.FormData).Encode()))
+		}
+		return ""
+	case string:
+		return l.body(r.Header.Get("Content-Type"), []byte(body))
+	case []byte:
+		return l.body(r.Header.Get("Content-Type"), body)
+	case io.Reader:
+		// Reading it would consume the request.
+		return fmt.Sprintf("[%T]", body)
+	default:
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Sprintf("[%T]", body)
+		}
+		return l.body("application/json", b)
+	}
+}
+
+// body redacts JSON and form bodies, other bodies are logged as is.
+func (l *AccessLog) body(contentType string, b []byte) string {
+	if strings.Contains(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(b)); err == nil {
+			return l.truncate([]byte(l.redactValues(values).Encode()))
+		}
+	}
+	var v interface{}
+	if json.Unmarshal(b, &v) == nil {
+		if redacted, err := json.Marshal(l.redactJSON(v)); err == nil {
+			b = redacted
+		}
+	}
+	return l.truncate(b)
+}
+
+func (l *AccessLog) redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			key := strings.ToLower(k)
+			switch {
+			case l.secrets.Has(key):
+				v[k] = redactedValue
+			case l.masked.Has(key):
+				if s, ok := fv.(string); ok {
+					v[k] = l.conf.Mask(s)
+				} else {
+					v[k] = redactedValue
+				}
+			default:
+				v[k] = l.redactJSON(fv)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = l.redactJSON(v[i])
+		}
+	}
+	return v
+}
+
+func (l *AccessLog) truncate(b []byte) string {
+	if len(b) <= l.conf.MaxBodySize {
+		return string(b)
+	}
+	return fmt.Sprintf("%s...(%d bytes)", b[:l.conf.MaxBodySize], len(b))
+}
@@ -0,0 +1,75 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/logx/logtest"
+)
+
+func TestAccessLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		_, _ = w.Write([]byte(`{"code":200,"data":{"access_token":"tok-123","account":{"email":"alice@example.com"},"bio":"` +
+			strings.Repeat("x", 100) + `"}}`))
+	}))
+	defer server.Close()
+
+	buf := logtest.NewCollector(t)
+	c := New(SetAccessLog(AccessLogConf{MaxBodySize: 80, SecretFields: []string{"pin"}}))
+	_, err := c.PostCtx(context.Background(), server.URL+"/login?sign=abc&page=1",
+		SetHeader("Authorization", "Bearer bearer-secret"),
+		SetBody(map[string]any{"phone": "13812345678", "password": "hunter2", "pin": 1234, "remark": "hi"}))
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, `"http access"`)
+	assert.Contains(t, out, `"status":200`)
+	assert.Contains(t, out, "page=1")
+	assert.Contains(t, out, "138****5678")
+	assert.Contains(t, out, "ali***@example.com")
+	assert.Contains(t, out, "bytes)")
+	for _, secret := range []string{"bearer-secret", "abc", "hunter2", "1234", "13812345678", "tok-123", "s3cr3t", "alice@"} {
+		assert.NotContains(t, out, secret)
+	}
+}
+
+func TestAccessLog_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	buf := logtest.NewCollector(t)
+	c := New(SetAccessLog(AccessLogConf{MaxBodySize: -1}))
+	_, err := c.GetCtx(context.Background(), server.URL, SetFormData(map[string]string{"token": "t0k"}))
+	assert.Error(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, `"level":"error"`)
+	assert.Contains(t, out, `"status":400`)
+	assert.NotContains(t, out, "req_body")
+}
+
+func TestAccessLog_ErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"msg":"expired","refresh_token":"rt-secret"}`))
+	}))
+	defer server.Close()
+
+	buf := logtest.NewCollector(t)
+	_, err := New(SetAccessLog(AccessLogConf{})).GetCtx(context.Background(), server.URL)
+	assert.Error(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, `"err":"Unauthorized"`)
+	assert.Contains(t, out, "expired")
+	assert.NotContains(t, out, "rt-secret")
+}
@@ -0,0 +1,466 @@
+package httpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/leclerc04/go-tool/agl/util/i18n"
+	"github.com/leclerc04/go-tool/agl/util/strs"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/zeromicro/go-zero/rest/pathvar"
+	"golang.org/x/text/language"
+)
+
+// DefaultMaxMemory is the memory used to parse multipart forms by Bind, the
+// rest of the files is stored on disk.
+const DefaultMaxMemory = 32 << 20
+
+// FieldError describes a field failing validation.
+type FieldError struct {
+	Field string `json:"field"`
+	Code  int    `json:"code"`
+	Msg   string `json:"msg"`
+}
+
+// Bind decodes r into v, a pointer to a struct, and validates it with
+// Validate. Fields are read with the go-zero tags:
+//
+//   - `path:"id"` from the path variables set by the go-zero router,
+//   - `form:"page"` from the query string and url-encoded or multipart forms,
+//   - `header:"X-Request-Id"` from the headers,
+//   - `json:"name"` from a JSON body.
+//
+// Options after a comma, like ",optional", are ignored: use the validate tag.
+// An empty value of a non-string field is absent, and time.Duration fields
+// are parsed by time.ParseDuration, e.g. "5s".
+// Failures are returned as an *errorx.Error with the failing fields in the
+// "fields" metadata, in the languages of i18n.RequestLanguages.
+func Bind(r *http.Request, v any) error {
+	langs := i18n.RequestLanguages(r)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("httpc: Bind needs a pointer to a struct, got %T", v))
+	}
+
+	var fieldErrs []FieldError
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) || typeErr.Field == "" {
+				return badRequest(langs, err)
+			}
+			fieldErrs = append(fieldErrs, newFieldError(langs, typeErr.Field, errorx.CodeFieldType, nil))
+		}
+	case mediaType == "multipart/form-data":
+		if err := r.ParseMultipartForm(DefaultMaxMemory); err != nil {
+			return badRequest(langs, err)
+		}
+	}
+	if err := r.ParseForm(); err != nil {
+		return badRequest(langs, err)
+	}
+
+	// A field with several tags takes the first source having a value.
+	sources := []bindSource{
+		{"path", func(name string) []string {
+			if value, ok := pathvar.Vars(r)[name]; ok {
+				return []string{value}
+			}
+			return nil
+		}},
+		{"form", func(name string) []string { return r.Form[name] }},
+		{"header", func(name string) []string { return r.Header.Values(name) }},
+	}
+	fieldErrs = append(fieldErrs, bindValues(langs, rv.Elem(), sources)...)
+	if len(fieldErrs) > 0 {
+		return newValidationError(langs, fieldErrs)
+	}
+	return Validate(langs, v)
+}
+
+// badRequest returns the error of a body which can't be read, 413 if it is
+// larger than the limit of http.MaxBytesReader.
+func badRequest(langs []language.Tag, err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errorx.New(http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge,
+			http.StatusText(http.StatusRequestEntityTooLarge)).
+			WithMetadata(errorx.Metadata{"limit": tooLarge.Limit}).WithError(err).Show()
+	}
+	return errorx.BadRequest("%s", errorx.CodeInvalidParams.Localize(langs, nil)).WithError(err).Show()
+}
+
+// bindSource reads the values of a field tagged with tag.
+type bindSource struct {
+	tag    string
+	values func(name string) []string
+}
+
+// bindValues sets the fields of rv tagged with the tag of one of sources.
+func bindValues(langs []language.Tag, rv reflect.Value, sources []bindSource) []FieldError {
+	var fieldErrs []FieldError
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			fieldErrs = append(fieldErrs, bindValues(langs, rv.Field(i), sources)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		for _, source := range sources {
+			name := tagName(sf.Tag.Get(source.tag))
+			if name == "" {
+				continue
+			}
+			values := source.values(name)
+			// An empty value of a non-string field is absent, left to required.
+			if len(values) == 0 || len(values) == 1 && values[0] == "" && !bindsString(sf.Type) {
+				continue
+			}
+			if err := setValues(rv.Field(i), values); err != nil {
+				fieldErrs = append(fieldErrs, newFieldError(langs, name, errorx.CodeFieldType, nil))
+			}
+			break
+		}
+	}
+	return fieldErrs
+}
+
+// bindsString tells whether a field of type t is set from strings as is.
+func bindsString(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+	return t.Kind() == reflect.String
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("httpc: can't bind %s", v.Type())
+	}
+	return nil
+}
+
+// Validate checks v, a struct or a pointer to one, against the rules of its
+// `validate` tags, separated by commas:
+//
+//   - required: the value is not zero,
+//   - min=N, max=N: bounds of the length of strings and slices, or of numbers,
+//   - enum=a|b|c: the value is one of those,
+//   - phone, email: see strs.IsValidPhone and strs.IsValidEmail,
+//   - regex=...: the value matches, it must be the last rule.
+//
+// Other rules are skipped for empty values which aren't required. Nested
+// structs are validated too. An invalid tag panics.
+func Validate(langs []language.Tag, v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	if fieldErrs := validateStruct(langs, "", rv); len(fieldErrs) > 0 {
+		return newValidationError(langs, fieldErrs)
+	}
+	return nil
+}
+
+func validateStruct(langs []language.Tag, prefix string, rv reflect.Value) []FieldError {
+	var fieldErrs []FieldError
+	for _, f := range structRules(rv.Type()) {
+		name := prefix + f.name
+		fv, err := rv.FieldByIndexErr(f.index)
+		if err != nil {
+			// A field of a nil embedded pointer.
+			continue
+		}
+		for _, rule := range f.rules {
+			if code, params := rule(fv); code != 0 {
+				fieldErrs = append(fieldErrs, newFieldError(langs, name, code, params))
+				break
+			}
+		}
+
+		fv = reflect.Indirect(fv)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			fieldErrs = append(fieldErrs, validateStruct(langs, name+".", fv)...)
+		case fv.Kind() == reflect.Slice && reflect.Indirect(reflect.New(fv.Type().Elem()).Elem()).Kind() == reflect.Struct:
+			for i := 0; i < fv.Len(); i++ {
+				if ev := reflect.Indirect(fv.Index(i)); ev.Kind() == reflect.Struct {
+					fieldErrs = append(fieldErrs, validateStruct(langs, name+"["+strconv.Itoa(i)+"].", ev)...)
+				}
+			}
+		}
+	}
+	return fieldErrs
+}
+
+func newFieldError(langs []language.Tag, field string, code errorx.ResCode, params map[string]any) FieldError {
+	all := map[string]any{"field": field}
+	for k, v := range params {
+		all[k] = v
+	}
+	return FieldError{Field: field, Code: int(code), Msg: code.Localize(langs, all)}
+}
+
+func newValidationError(langs []language.Tag, fieldErrs []FieldError) error {
+	msgs := make([]string, len(fieldErrs))
+	for i, fe := range fieldErrs {
+		msgs[i] = fe.Msg
+	}
+	return errorx.New(http.StatusText(http.StatusBadRequest), int(errorx.CodeInvalidParams), strings.Join(msgs, "; ")).
+		WithMetadata(errorx.Metadata{"fields": fieldErrs}).Show()
+}
+
+// tagName returns the name of a field tag, without its options.
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+type fieldRules struct {
+	index []int
+	name  string
+	rules []rule
+}
+
+// rule returns the code of the failure of v and its message parameters, or
+// zero if v is valid.
+type rule func(v reflect.Value) (errorx.ResCode, map[string]any)
+
+var rulesCache sync.Map // reflect.Type -> []fieldRules
+
+// structRules returns the exported fields of t with their rules, including
+// the ones promoted from embedded structs.
+func structRules(t reflect.Type) []fieldRules {
+	if v, ok := rulesCache.Load(t); ok {
+		return v.([]fieldRules)
+	}
+	var fields []fieldRules
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		name := sf.Name
+		for _, tag := range []string{"json", "form", "path", "header"} {
+			if n := tagName(sf.Tag.Get(tag)); n != "" {
+				name = n
+				break
+			}
+		}
+		fields = append(fields, fieldRules{index: sf.Index, name: name, rules: parseRules(t, sf)})
+	}
+	rulesCache.Store(t, fields)
+	return fields
+}
+
+func parseRules(t reflect.Type, sf reflect.StructField) []rule {
+	tag := sf.Tag.Get("validate")
+	if tag == "" {
+		return nil
+	}
+	var pattern string
+	if i := strings.Index(tag, "regex="); i >= 0 {
+		tag, pattern = strings.TrimSuffix(tag[:i], ","), tag[i+len("regex="):]
+	}
+
+	var rules []rule
+	required := false
+	for _, r := range strings.Split(tag, ",") {
+		key, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+		switch key {
+		case "":
+		case "required":
+			required = true
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("httpc: invalid %s on %s.%s: %v", key, t, sf.Name, err))
+			}
+			rules = append(rules, boundRule(key, arg, bound))
+		case "enum":
+			rules = append(rules, enumRule(strings.Split(arg, "|")))
+		case "phone":
+			rules = append(rules, stringRule(errorx.CodeFieldPhone, strs.IsValidPhone))
+		case "email":
+			rules = append(rules, stringRule(errorx.CodeFieldEmail, strs.IsValidEmail))
+		default:
+			panic(fmt.Sprintf("httpc: unknown validate rule %q on %s.%s", key, t, sf.Name))
+		}
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			panic(fmt.Sprintf("httpc: invalid regex on %s.%s: %v", t, sf.Name, err))
+		}
+		rules = append(rules, stringRule(errorx.CodeFieldPattern, re.MatchString))
+	}
+
+	for i := range rules {
+		rules[i] = skipZero(rules[i])
+	}
+	if required {
+		rules = append([]rule{func(v reflect.Value) (errorx.ResCode, map[string]any) {
+			if isEmpty(v) {
+				return errorx.CodeFieldRequired, nil
+			}
+			return 0, nil
+		}}, rules...)
+	}
+	return rules
+}
+
+// skipZero makes r accept empty values, and see through pointers.
+func skipZero(r rule) rule {
+	return func(v reflect.Value) (errorx.ResCode, map[string]any) {
+		if isEmpty(v) {
+			return 0, nil
+		}
+		return r(reflect.Indirect(v))
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func boundRule(key, arg string, bound float64) rule {
+	return func(v reflect.Value) (errorx.ResCode, map[string]any) {
+		var n float64
+		length := true
+		switch v.Kind() {
+		case reflect.String:
+			n = float64(utf8.RuneCountInString(v.String()))
+		case reflect.Slice, reflect.Map, reflect.Array:
+			n = float64(v.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, length = float64(v.Int()), false
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, length = float64(v.Uint()), false
+		case reflect.Float32, reflect.Float64:
+			n, length = v.Float(), false
+		default:
+			return 0, nil
+		}
+		switch {
+		case key == "min" && n < bound && length:
+			return errorx.CodeFieldTooShort, map[string]any{"min": arg}
+		case key == "min" && n < bound:
+			return errorx.CodeFieldTooSmall, map[string]any{"min": arg}
+		case key == "max" && n > bound && length:
+			return errorx.CodeFieldTooLong, map[string]any{"max": arg}
+		case key == "max" && n > bound:
+			return errorx.CodeFieldTooLarge, map[string]any{"max": arg}
+		}
+		return 0, nil
+	}
+}
+
+func enumRule(values []string) rule {
+	return func(v reflect.Value) (errorx.ResCode, map[string]any) {
+		s := fmt.Sprint(v.Interface())
+		for _, value := range values {
+			if s == value {
+				return 0, nil
+			}
+		}
+		return errorx.CodeFieldEnum, map[string]any{"values": strings.Join(values, ", ")}
+	}
+}
+
+func stringRule(code errorx.ResCode, valid func(string) bool) rule {
+	return func(v reflect.Value) (errorx.ResCode, map[string]any) {
+		if v.Kind() == reflect.String && !valid(v.String()) {
+			return code, nil
+		}
+		return 0, nil
+	}
+}
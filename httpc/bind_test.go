@@ -0,0 +1,142 @@
+package httpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/rest/pathvar"
+)
+
+type Paging struct {
+	Page int `form:"page,optional" validate:"min=1"`
+	Size int `form:"size,optional" validate:"max=100"`
+}
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type bindRequest struct {
+	Paging
+	ID        int64     `path:"id"`
+	RequestID string    `header:"X-Request-Id"`
+	Tags      []string  `form:"tag" validate:"max=2"`
+	Name      string    `json:"name" validate:"required,min=2,max=5"`
+	Role      string    `json:"role" validate:"enum=admin|user"`
+	Phone     string    `json:"phone" validate:"phone"`
+	Email     *string   `json:"email" validate:"required,email"`
+	Code      string    `json:"code" validate:"regex=^[A-Z]{2},[0-9]+$"`
+	Addresses []Address `json:"addresses"`
+}
+
+func newBindRequest(query, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/users/42?"+query, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("X-Request-Id", "abc")
+	return pathvar.WithVars(r, map[string]string{"id": "42"})
+}
+
+func TestBind(t *testing.T) {
+	var req bindRequest
+	err := Bind(newBindRequest("page=2&tag=a&tag=b",
+		`{"name": "bob", "role": "admin", "email": "bob@example.com", "code": "AB,12", "addresses": [{"city": "Paris"}]}`), &req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), req.ID)
+	assert.Equal(t, "abc", req.RequestID)
+	assert.Equal(t, 2, req.Page)
+	assert.Equal(t, []string{"a", "b"}, req.Tags)
+	assert.Equal(t, "bob", req.Name)
+	assert.Equal(t, "bob@example.com", *req.Email)
+	assert.Equal(t, []Address{{City: "Paris"}}, req.Addresses)
+}
+
+func TestBind_SourceOrder(t *testing.T) {
+	var req struct {
+		Org string `path:"org" form:"org" header:"X-Org"`
+	}
+	r := httptest.NewRequest(http.MethodGet, "/orgs/acme?org=query", nil)
+	r.Header.Set("X-Org", "header")
+	r = pathvar.WithVars(r, map[string]string{"org": "acme"})
+	// The path wins over the form, which wins over the header.
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, Bind(r, &req))
+		assert.Equal(t, "acme", req.Org)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/orgs?org=query", nil)
+	r.Header.Set("X-Org", "header")
+	assert.NoError(t, Bind(r, &req))
+	assert.Equal(t, "query", req.Org)
+}
+
+func TestBind_FieldErrors(t *testing.T) {
+	r := newBindRequest("page=-1&size=200&tag=a&tag=b&tag=c",
+		`{"name": "bobby-tables", "role": "root", "phone": "123", "code": "ab", "addresses": [{"city": ""}]}`)
+	r.Header.Set("Accept-Language", "en")
+	var req bindRequest
+	err := Bind(r, &req)
+
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, int(errorx.CodeInvalidParams), e.Code)
+	assert.True(t, e.IsShow)
+	assert.Equal(t, []FieldError{
+		{"page", int(errorx.CodeFieldTooSmall), "page must be at least 1"},
+		{"size", int(errorx.CodeFieldTooLarge), "size must be at most 100"},
+		{"tag", int(errorx.CodeFieldTooLong), "The length of tag must be at most 2"},
+		{"name", int(errorx.CodeFieldTooLong), "The length of name must be at most 5"},
+		{"role", int(errorx.CodeFieldEnum), "role must be one of admin, user"},
+		{"phone", int(errorx.CodeFieldPhone), "phone is not a valid phone number"},
+		{"email", int(errorx.CodeFieldRequired), "email is required"},
+		{"code", int(errorx.CodeFieldPattern), "code has an invalid format"},
+		{"addresses[0].city", int(errorx.CodeFieldRequired), "addresses[0].city is required"},
+	}, e.Metadata["fields"])
+}
+
+func TestBind_Form(t *testing.T) {
+	var req struct {
+		Page  int    `form:"page"`
+		Email string `form:"email" validate:"email"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/?page=x", strings.NewReader(url.Values{"email": {"nope"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := Bind(r, &req)
+
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	fields := e.Metadata["fields"].([]FieldError)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, FieldError{"page", int(errorx.CodeFieldType), "page格式错误"}, fields[0])
+
+	err = Bind(httptest.NewRequest(http.MethodPost, "/?email=bob@example.com", nil), &req)
+	assert.NoError(t, err)
+}
+
+func TestBind_EmptyValues(t *testing.T) {
+	var req struct {
+		Page    int           `form:"page"`
+		Size    int           `form:"size" validate:"required"`
+		Timeout time.Duration `form:"timeout"`
+		Name    string        `form:"name"`
+	}
+	r := httptest.NewRequest(http.MethodGet, "/?page=&size=&timeout=5s&name=", nil)
+	r.Header.Set("Accept-Language", "en")
+	err := Bind(r, &req)
+
+	// Empty numbers are absent rather than malformed.
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, []FieldError{{"size", int(errorx.CodeFieldRequired), "size is required"}}, e.Metadata["fields"])
+	assert.Equal(t, 5*time.Second, req.Timeout)
+
+	err = Bind(httptest.NewRequest(http.MethodGet, "/?page=&size=3&timeout=5", nil), &req)
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, "timeout", e.Metadata["fields"].([]FieldError)[0].Field)
+}
@@ -0,0 +1,213 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+	"github.com/leclerc04/go-tool/errorx"
+)
+
+// BizTypeCircuitOpen is the BizType of errors returned when a request is
+// rejected by an open circuit breaker.
+const BizTypeCircuitOpen = "http-CircuitOpen"
+
+var (
+	metricBreakerState = mon.NewGaugeVec(
+		"httpc", "circuit_breaker_state", "State of circuit breakers, 0: closed, 1: half-open, 2: open.",
+		[]string{"name", "host"})
+	metricBreakerRejectedTotal = mon.NewCounterVec(
+		"httpc", "circuit_breaker_rejected_total", "Number of requests rejected by open circuit breakers.",
+		[]string{"name", "host"})
+)
+
+// BreakerState is the state of the circuit of a host.
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// BreakerConf configures a CircuitBreaker.
+type BreakerConf struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before letting probes through.
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests is the number of concurrent probes allowed when half-open.
+	HalfOpenMaxRequests int
+}
+
+// DefaultBreakerConf is used for the zero fields of BreakerConf.
+var DefaultBreakerConf = BreakerConf{
+	FailureThreshold:    5,
+	OpenTimeout:         30 * time.Second,
+	HalfOpenMaxRequests: 1,
+}
+
+// CircuitBreaker keeps a circuit per host. Once a host fails
+// FailureThreshold times in a row, requests to it fail fast until
+// OpenTimeout elapses, then a few probes decide whether to close the circuit
+// again. A CircuitBreaker can be shared by several clients.
+type CircuitBreaker struct {
+	name string
+	conf BreakerConf
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// NewCircuitBreaker creates a CircuitBreaker, name is used as metric label.
+func NewCircuitBreaker(name string, conf BreakerConf) *CircuitBreaker {
+	if conf.FailureThreshold <= 0 {
+		conf.FailureThreshold = DefaultBreakerConf.FailureThreshold
+	}
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = DefaultBreakerConf.OpenTimeout
+	}
+	if conf.HalfOpenMaxRequests <= 0 {
+		conf.HalfOpenMaxRequests = DefaultBreakerConf.HalfOpenMaxRequests
+	}
+	return &CircuitBreaker{
+		name:     name,
+		conf:     conf,
+		circuits: map[string]*circuit{},
+	}
+}
+
+// State returns the current state of the circuit of host.
+func (b *CircuitBreaker) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		return BreakerClosed
+	}
+	return c.state
+}
+
+// Allow checks whether a request to host may be sent. If it returns nil,
+// the caller must report the outcome with Done.
+func (b *CircuitBreaker) Allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	if c.state == BreakerOpen && timeutil.Now().Sub(c.openedAt) >= b.conf.OpenTimeout {
+		b.setState(host, c, BreakerHalfOpen)
+	}
+	switch c.state {
+	case BreakerHalfOpen:
+		if c.probes < b.conf.HalfOpenMaxRequests {
+			c.probes++
+			return nil
+		}
+	case BreakerClosed:
+		return nil
+	}
+	metricBreakerRejectedTotal.With(mon.Labels{"name": b.name, "host": host}).Inc()
+	return errorx.New(BizTypeCircuitOpen, http.StatusServiceUnavailable, "circuit breaker is open").
+		WithMetadata(errorx.Metadata{"breaker": b.name, "host": host, "state": c.state.String()})
+}
+
+// Done reports the outcome of a request allowed by Allow.
+func (b *CircuitBreaker) Done(host string, res *resty.Response, err error) {
+	success, counted := breakerOutcome(res, err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	if c.state == BreakerHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+	if !counted {
+		return
+	}
+	if success {
+		c.failures = 0
+		if c.state != BreakerClosed {
+			b.setState(host, c, BreakerClosed)
+		}
+		return
+	}
+	c.failures++
+	if c.state == BreakerHalfOpen || (c.state == BreakerClosed && c.failures >= b.conf.FailureThreshold) {
+		c.openedAt = timeutil.Now()
+		b.setState(host, c, BreakerOpen)
+	}
+}
+
+func (b *CircuitBreaker) circuit(host string) *circuit {
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{}
+		b.circuits[host] = c
+		metricBreakerState.With(mon.Labels{"name": b.name, "host": host}).Set(float64(BreakerClosed))
+	}
+	return c
+}
+
+func (b *CircuitBreaker) setState(host string, c *circuit, state BreakerState) {
+	c.state = state
+	c.probes = 0
+	metricBreakerState.With(mon.Labels{"name": b.name, "host": host}).Set(float64(state))
+}
+
+// IsCircuitOpen tells whether err is caused by an open circuit breaker.
+func IsCircuitOpen(err error) bool {
+	var e *errorx.Error
+	return errors.As(err, &e) && e.BizType == BizTypeCircuitOpen
+}
+
+// breakerOutcome classifies the outcome of a request for the breaker:
+// transport errors and 5xx responses count as failures, while the caller's
+// ctx being done counts as neither.
+func breakerOutcome(res *resty.Response, err error) (success, counted bool) {
+	if res != nil && res.StatusCode() != 0 {
+		return res.StatusCode() < http.StatusInternalServerError, true
+	}
+	if err == nil {
+		return true, true
+	}
+	if errs.IsCancelled(err) || errs.Unwrap(err) == context.DeadlineExceeded {
+		return false, false
+	}
+	return false, true
+}
+
+// requestHost returns the host a request to rawURL is sent to.
+func (c *Client) requestHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	if u, err := url.Parse(c.Client.BaseURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
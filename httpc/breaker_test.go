@@ -0,0 +1,49 @@
+package httpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	timeutil.SetAFakeTime()
+	defer timeutil.UnSetFakeTime()
+
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	b := NewCircuitBreaker("test", BreakerConf{FailureThreshold: 2, OpenTimeout: time.Minute})
+	cl := New(SetCircuitBreaker(b), SetRetryCount(0))
+
+	for i := 0; i < 2; i++ {
+		_, err := cl.Get(server.URL)
+		assert.Error(t, err)
+		assert.False(t, IsCircuitOpen(err))
+	}
+	assert.Equal(t, BreakerOpen, b.State(u.Host))
+
+	_, err := cl.Get(server.URL)
+	assert.True(t, IsCircuitOpen(err))
+	assert.Equal(t, int32(2), hits.Load())
+
+	timeutil.AdvanceFakeTime(time.Minute)
+	healthy.Store(true)
+	_, err = cl.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, b.State(u.Host))
+}
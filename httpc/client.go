@@ -1,13 +1,18 @@
 package httpc
 
 import (
-	"bytes"
+	"context"
 	"io"
 	"net/http"
-	"os"
+	neturl "net/url"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/base/trace"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
 	"github.com/leclerc04/go-tool/errorx"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -25,124 +30,291 @@ type Client struct {
 	BaseURI string // BaseURI is the base uri for every request, starting with a slash, for example: /api/v1
 	//IgnoreCodes sets.Int // IgnoreCodes ignores some code to be returned as an error.
 	IgnoreCodes sets.Set[int]
+	StrictJSON  bool            // StrictJSON rejects unknown fields in the data of envelopes decoded by CallJSON.
+	Breaker     *CircuitBreaker // Breaker, if set, fails requests fast while their host keeps failing.
+	Limiter     *RateLimiter    // Limiter, if set, throttles requests to the upstream.
+	Retry       RetryPolicy     // Retry decides which failed requests are sent again, see Client.SetRetryCount.
+	TokenSource TokenSource     // TokenSource, if set, authorizes every request with a fresh token.
+	AccessLog   *AccessLog      // AccessLog, if set, logs every request with secrets redacted.
+	Hedger      *Hedger         // Hedger, if set, races slow idempotent requests with a second one.
+	// Body configures the decompression, charset conversion and size limit of
+	// responses. It is read by New only. Bodies declaring a GBK or GB18030
+	// charset are converted to utf-8, and their size is not limited unless
+	// SetMaxResponseSize is given.
+	Body httpie.BodyConf
+	// PathTemplater maps request paths to metric labels, DefaultPathTemplater if nil.
+	PathTemplater PathTemplater
+
+	transport string // transport is the name of the shared transport, empty if SetTransport replaced it.
+	// doNotParseResponse mirrors the flag of resty, see Client.SetDoNotParseResponse.
+	doNotParseResponse bool
 }
 
 func Get(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Get(url, rfs...)
+	return GetCtx(context.Background(), url, rfs...)
 }
 
 func Post(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Post(url, rfs...)
+	return PostCtx(context.Background(), url, rfs...)
 }
 
 func Patch(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Patch(url, rfs...)
+	return PatchCtx(context.Background(), url, rfs...)
 }
 
 func Put(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Put(url, rfs...)
+	return PutCtx(context.Background(), url, rfs...)
 }
 
 func Delete(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Delete(url, rfs...)
+	return DeleteCtx(context.Background(), url, rfs...)
 }
 
 func Head(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Head(url, rfs...)
+	return HeadCtx(context.Background(), url, rfs...)
 }
 
 func Options(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return New().Options(url, rfs...)
+	return OptionsCtx(context.Background(), url, rfs...)
 }
 
-// Download retrieves content from the given url and write it to path.
-func Download(url, path string, rfs ...RequestFunc) error {
-	// download may take more time
-	cl := New(UnsetTimeout())
-	res, err := cl.Get(url, rfs...)
-	if err != nil {
-		return err
-	}
+func GetCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).GetCtx(ctx, url, rfs...)
+}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = f.Close()
-	}()
+func PostCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).PostCtx(ctx, url, rfs...)
+}
 
-	_, err = io.Copy(f, bytes.NewReader(res.Body()))
+func PatchCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).PatchCtx(ctx, url, rfs...)
+}
+
+func PutCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).PutCtx(ctx, url, rfs...)
+}
 
-	return err
+func DeleteCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).DeleteCtx(ctx, url, rfs...)
 }
 
+func HeadCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).HeadCtx(ctx, url, rfs...)
+}
+
+func OptionsCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return Named(DefaultTransportName).OptionsCtx(ctx, url, rfs...)
+}
+
+// New creates a client. Clients share the connections of their transport,
+// DefaultTransportName unless UseTransport or SetTransport is given.
 func New(cfs ...ClientFunc) *Client {
 	r := resty.New()
-	r.SetHeader("Content-Type", "application/json").
+	r.SetTransport(SharedTransport(DefaultTransportName)).
+		SetHeader("Content-Type", "application/json").
 		SetHeader("Accept", "application/json").
 		SetHeader("User-Agent", UserAgent).
-		SetTimeout(TimeoutSeconds * time.Second).
-		SetRetryCount(MaxRetryCount)
+		SetTimeout(TimeoutSeconds * time.Second)
 
 	c := &Client{
 		Client:      r,
 		IgnoreCodes: sets.New[int](),
+		Retry:       DefaultRetryPolicy,
+		Body:        httpie.BodyConf{ConvertCharset: true},
+		transport:   DefaultTransportName,
 	}
 
 	for _, cf := range cfs {
 		cf(c)
 	}
+	c.wrapTransport(c.GetClient().Transport)
 	return c
 }
 
 func (c *Client) Get(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodGet, url, rfs...)
+	return c.GetCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Post(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodPost, url, rfs...)
+	return c.PostCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Patch(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodPatch, url, rfs...)
+	return c.PatchCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Put(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodPut, url, rfs...)
+	return c.PutCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Delete(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodDelete, url, rfs...)
+	return c.DeleteCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Head(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodHead, url, rfs...)
+	return c.HeadCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Options(url string, rfs ...RequestFunc) (*resty.Response, error) {
-	return c.Request(resty.MethodOptions, url, rfs...)
+	return c.OptionsCtx(context.Background(), url, rfs...)
 }
 
 func (c *Client) Request(method, url string, rfs ...RequestFunc) (*resty.Response, error) {
-	if c.BaseURI != "" {
+	return c.RequestCtx(context.Background(), method, url, rfs...)
+}
+
+func (c *Client) GetCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodGet, url, rfs...)
+}
+
+func (c *Client) PostCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodPost, url, rfs...)
+}
+
+func (c *Client) PatchCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodPatch, url, rfs...)
+}
+
+func (c *Client) PutCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodPut, url, rfs...)
+}
+
+func (c *Client) DeleteCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodDelete, url, rfs...)
+}
+
+func (c *Client) HeadCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodHead, url, rfs...)
+}
+
+func (c *Client) OptionsCtx(ctx context.Context, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	return c.RequestCtx(ctx, resty.MethodOptions, url, rfs...)
+}
+
+// RequestCtx sends the request bound to ctx. The deadline of ctx applies to
+// the whole call including retries, and once ctx is done the pending attempt
+// and any further retries are abandoned. In that case the returned error
+// satisfies errs.IsCancelled for a cancelled ctx.
+func (c *Client) RequestCtx(ctx context.Context, method, url string, rfs ...RequestFunc) (*resty.Response, error) {
+	if c.TokenSource != nil {
+		return c.requestWithToken(ctx, method, url, rfs)
+	}
+	return c.request(ctx, method, url, rfs)
+}
+
+func (c *Client) request(ctx context.Context, method, url string, rfs []RequestFunc) (res *resty.Response, err error) {
+	if c.BaseURI != "" && !isAbsURL(url) {
 		url = c.BaseURI + url
 	}
-	r := c.R()
+	if c.Breaker != nil {
+		host := c.requestHost(url)
+		if err = c.Breaker.Allow(host); err != nil {
+			return nil, err
+		}
+		defer func() {
+			c.Breaker.Done(host, res, err)
+		}()
+	}
+
+	o := c.observe(ctx, method, url)
+	start := time.Now()
+	var r *resty.Request
+	for attempt := 0; ; attempt++ {
+		if c.Hedger != nil {
+			r, res, err = c.executeHedged(ctx, rfs, method, url)
+		} else {
+			r = c.newRequest(ctx, rfs)
+			res, err = c.execute(ctx, r, method, url)
+		}
+		o.attempt(res)
+		if wait, ok := throttled(res); ok && c.Limiter != nil {
+			// The upstream asks us to slow down, pause everyone sharing the
+			// limiter.
+			metricLimiterThrottledTotal.With(mon.Labels{"name": c.Limiter.name}).Inc()
+			c.Limiter.Pause(c.limitKey(url), wait)
+		}
+		if err == nil && (!res.IsError() || c.IgnoreCodes.Has(res.StatusCode())) {
+			break
+		}
+		delay, reason, ok := c.Retry.retryDelay(r, attempt, time.Since(start), res, err)
+		if !ok {
+			break
+		}
+		trace.Printc(ctx, "http retry", "reason", reason, "delay", delay)
+		discardBody(res)
+		if err = timeutil.Sleep(ctx, delay); err != nil {
+			break
+		}
+		o.retry()
+	}
+	res, err = c.wrapError(ctx, res, err)
+	o.finish(res, err)
+	if c.AccessLog != nil {
+		c.AccessLog.log(ctx, r, res, err, time.Since(start), o.retries)
+	}
+	return res, err
+}
+
+// isAbsURL tells whether rawURL has a scheme, e.g. a URL returned by the
+// upstream. Neither BaseURI nor the host URL of resty apply to it.
+func isAbsURL(rawURL string) bool {
+	u, err := neturl.Parse(rawURL)
+	return err == nil && u.IsAbs()
+}
+
+func (c *Client) newRequest(ctx context.Context, rfs []RequestFunc) *resty.Request {
+	r := c.R().SetContext(ctx)
+	if id := RequestID(ctx); id != "" {
+		r.SetHeader(RequestIDHeader, id)
+	}
 
 	for _, rf := range rfs {
 		rf(r)
 	}
+	return r
+}
+
+// execute sends one request, waiting for the limiter if any.
+func (c *Client) execute(ctx context.Context, r *resty.Request, method, url string) (*resty.Response, error) {
+	// Method is read by the retry policy before Execute sets it.
+	r.Method = method
+	if c.Limiter != nil {
+		done, err := c.Limiter.Wait(ctx, c.limitKey(url))
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	return r.Execute(method, url)
+}
+
+// maxDiscardSize is the most read from a discarded body to reuse its
+// connection, larger bodies close it.
+const maxDiscardSize = 64 << 10
 
-	return c.wrapError(r.Execute(method, url))
+// discardBody releases the body of an attempt that is retried, which
+// SetDoNotParseResponse leaves open.
+func discardBody(res *resty.Response) {
+	if res == nil || res.RawResponse == nil || res.RawResponse.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.RawResponse.Body, maxDiscardSize))
+	_ = res.RawResponse.Body.Close()
 }
 
-func (c *Client) wrapError(res *resty.Response, err error) (*resty.Response, error) {
+func (c *Client) wrapError(ctx context.Context, res *resty.Response, err error) (*resty.Response, error) {
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return res, errs.Wrap(ctxErr)
+		}
 		return res, err
 	}
 	code := res.StatusCode()
 	if res.IsError() && !c.IgnoreCodes.Has(code) {
+		if e, ok := NewErrorFromProblem(res); ok {
+			return res, e
+		}
 		return res, errorx.New(http.StatusText(code), code, res.String()).
 			WithMetadata(errorx.Metadata{"err": res.Error()})
 	}
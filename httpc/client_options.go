@@ -2,7 +2,11 @@ package httpc
 
 import (
 	"crypto/tls"
+	"net/http"
 	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
 )
 
 type ClientFunc func(*Client)
@@ -25,6 +29,20 @@ func SetAuthToken(token string) ClientFunc {
 	}
 }
 
+// SetTokenSource authorizes requests with tokens from ts, e.g. an OAuth2TokenSource.
+func SetTokenSource(ts TokenSource) ClientFunc {
+	return func(c *Client) {
+		c.TokenSource = ts
+	}
+}
+
+// SetAccessLog logs every request of the client, see AccessLogConf for redaction.
+func SetAccessLog(conf AccessLogConf) ClientFunc {
+	return func(c *Client) {
+		c.AccessLog = NewAccessLog(conf)
+	}
+}
+
 func SetBaseURI(uri string) ClientFunc {
 	return func(c *Client) {
 		c.BaseURI = uri
@@ -40,7 +58,7 @@ func SetHostURL(url string) ClientFunc {
 
 func SetProxy(proxyURL string) ClientFunc {
 	return func(c *Client) {
-		c.Client.SetProxy(proxyURL)
+		c.SetProxy(proxyURL)
 	}
 }
 
@@ -56,21 +74,105 @@ func SetIgnoreCodes(codes ...int) ClientFunc {
 	}
 }
 
+// SetStrictJSON makes CallJSON reject response fields unknown to the target type.
+func SetStrictJSON() ClientFunc {
+	return func(c *Client) {
+		c.StrictJSON = true
+	}
+}
+
+// SetCircuitBreaker makes the client fail fast on hosts tripped in b.
+func SetCircuitBreaker(b *CircuitBreaker) ClientFunc {
+	return func(c *Client) {
+		c.Breaker = b
+	}
+}
+
+// SetRateLimiter throttles the requests of the client with l.
+func SetRateLimiter(l *RateLimiter) ClientFunc {
+	return func(c *Client) {
+		c.Limiter = l
+	}
+}
+
+// SetPathTemplater sets how request paths are turned into metric labels.
+func SetPathTemplater(t PathTemplater) ClientFunc {
+	return func(c *Client) {
+		c.PathTemplater = t
+	}
+}
+
+// SetHedger hedges slow idempotent requests with h.
+func SetHedger(h *Hedger) ClientFunc {
+	return func(c *Client) {
+		c.Hedger = h
+	}
+}
+
 func SetRetryCount(count int) ClientFunc {
 	return func(c *Client) {
-		c.Client.SetRetryCount(count)
+		c.Retry.MaxRetries = count
 	}
 }
 
 func SetRetryWaitTime(waitTime time.Duration) ClientFunc {
 	return func(c *Client) {
-		c.Client.SetRetryWaitTime(waitTime)
+		c.Retry.BaseDelay = waitTime
+	}
+}
+
+// SetRetryPolicy replaces the whole retry policy of the client.
+func SetRetryPolicy(p RetryPolicy) ClientFunc {
+	return func(c *Client) {
+		c.Retry = p
+	}
+}
+
+// SetTransport replaces the http.RoundTripper, e.g. with a cassette.Recorder in tests.
+func SetTransport(rt http.RoundTripper) ClientFunc {
+	return func(c *Client) {
+		c.SetTransport(rt)
+	}
+}
+
+// UseTransport sends requests through the shared transport registered as
+// name, see RegisterTransport. Clients use DefaultTransportName by default.
+func UseTransport(name string) ClientFunc {
+	return func(c *Client) {
+		c.Client.SetTransport(SharedTransport(name))
+		c.transport = name
+	}
+}
+
+// SetSigner signs every attempt of every request with s, e.g. an
+// oauthutil.Signer. It takes the resty pre-request hook of the client.
+func SetSigner(s httpie.Signer) ClientFunc {
+	return func(c *Client) {
+		c.Client.SetPreRequestHook(func(_ *resty.Client, r *http.Request) error {
+			return s.Sign(r)
+		})
+	}
+}
+
+// SetResponseBody configures the decompression, charset conversion and size
+// limit of responses, see httpie.BodyConf.
+func SetResponseBody(conf httpie.BodyConf) ClientFunc {
+	return func(c *Client) {
+		c.Body = conf
+	}
+}
+
+// SetMaxResponseSize fails reading responses larger than n bytes, 0 for no
+// limit, the default as in httpie. httpie.DefaultMaxBodySize suits most APIs.
+func SetMaxResponseSize(n int64) ClientFunc {
+	return func(c *Client) {
+		c.Body.MaxSize = n
 	}
 }
 
 func SetTLSClientConfig(config *tls.Config) ClientFunc {
 	return func(c *Client) {
-		c.Client.SetTLSClientConfig(config)
+		c.SetTLSClientConfig(config)
 	}
 }
 
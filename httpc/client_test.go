@@ -0,0 +1,113 @@
+package httpc
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+	"github.com/leclerc04/go-tool/agl/util/oauthutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestRequestCtx_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := GetCtx(ctx, server.URL)
+	assert.True(t, errs.IsCancelled(err), "unexpected error: %v", err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRequestCtx_StopsRetrying(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cl := New(SetRetryWaitTime(time.Second))
+	_, err := cl.GetCtx(ctx, server.URL)
+	assert.True(t, errs.IsCancelled(err), "unexpected error: %v", err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestFetchCtx_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := FetchCtx(ctx, &FetchConf{Method: http.MethodGet, URL: "http://127.0.0.1:1"})
+	assert.True(t, errs.IsCancelled(err), "unexpected error: %v", err)
+}
+
+type hmacStore map[string]bool
+
+func (s hmacStore) GetSecret(key string) (string, error) { return "secret", nil }
+
+func (s hmacStore) CheckUniqueness(nonce, timestamp, key string) (bool, error) {
+	if s[nonce] {
+		return false, nil
+	}
+	s[nonce] = true
+	return true, nil
+}
+
+func TestSetSigner_SignsEachAttempt(t *testing.T) {
+	store := hmacStore{}
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if err := oauthutil.VerifyHMAC(r, store); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	c := New(SetSigner(&oauthutil.HMACSigner{Key: "svc", Secret: "secret"}), SetRetryWaitTime(time.Millisecond))
+	res, err := c.PutCtx(context.Background(), server.URL+"/x?a=1", SetBody(`{"a":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode())
+	assert.Equal(t, 2, attempts)
+}
+
+func TestResponseBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=gb18030")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		body, _ := simplifiedchinese.GB18030.NewEncoder().String(`{"name":"张三` + strings.Repeat(" ", 100) + `"}`)
+		_, _ = gz.Write([]byte(body))
+		_ = gz.Close()
+	}))
+	defer server.Close()
+
+	var v struct{ Name string }
+	_, err := New().GetCtx(context.Background(), server.URL, SetResult(&v))
+	assert.NoError(t, err)
+	assert.Equal(t, "张三"+strings.Repeat(" ", 100), v.Name)
+
+	_, err = New(SetMaxResponseSize(50), SetRetryCount(0)).GetCtx(context.Background(), server.URL)
+	assert.True(t, httpie.IsBodyTooLarge(err), "unexpected error: %v", err)
+}
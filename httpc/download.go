@@ -0,0 +1,338 @@
+package httpc
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+	"github.com/leclerc04/go-tool/errorx"
+)
+
+const (
+	// DefaultMaxResume is how many times a download is resumed after the
+	// transfer is interrupted.
+	DefaultMaxResume = 3
+
+	partSuffix      = ".part"
+	validatorSuffix = ".part.validator"
+)
+
+// errContentChanged tells the partial content no longer matches the remote
+// one, the download starts over.
+var errContentChanged = errors.New("httpc: content changed since the partial download")
+
+// DownloadConf describes a file download.
+type DownloadConf struct {
+	URL  string
+	Path string // Path is the destination, it is only created once the whole content is received.
+
+	SHA256 string // SHA256 is the expected hex digest of the content, if set.
+	MD5    string // MD5 is the expected hex digest of the content, if set.
+
+	// MaxResume limits how many times an interrupted transfer is resumed with
+	// a Range request. Zero means DefaultMaxResume, negative disables resuming.
+	MaxResume int
+
+	// Progress, if set, is called after each chunk is written. total is -1
+	// when the server doesn't tell the content length.
+	Progress func(written, total int64)
+}
+
+// Download retrieves content from the given url and write it to path.
+func Download(url, path string, rfs ...RequestFunc) error {
+	return DownloadCtx(context.Background(), url, path, rfs...)
+}
+
+// DownloadCtx is like Download, but aborts the transfer once ctx is done.
+func DownloadCtx(ctx context.Context, url, path string, rfs ...RequestFunc) error {
+	return DownloadFile(ctx, &DownloadConf{URL: url, Path: path}, rfs...)
+}
+
+// DownloadFile streams the content of conf.URL into a temporary file next to
+// conf.Path, and renames it to conf.Path once complete and verified. The
+// temporary file is kept when the download fails, so a later call with the
+// same path continues where the previous one stopped. Resuming sends the
+// ETag or Last-Modified of the first response in If-Range, so a change
+// upstream restarts the download instead of splicing both versions. When the
+// server gives neither, only downloads verified by a checksum are resumed.
+func DownloadFile(ctx context.Context, conf *DownloadConf, rfs ...RequestFunc) error {
+	maxResume := conf.MaxResume
+	if maxResume == 0 {
+		maxResume = DefaultMaxResume
+	}
+	tmpPath := conf.Path + partSuffix
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	d := &downloader{conf: conf, f: f, total: -1, validatorPath: conf.Path + validatorSuffix}
+	if maxResume < 0 {
+		err = d.reset()
+	} else {
+		err = d.restore()
+	}
+	if err != nil {
+		return err
+	}
+
+	// download may take more time, and interruptions are handled by resuming.
+	// The content is saved as sent, without charset conversion nor size limit.
+	cl := New(UnsetTimeout(), SetRetryCount(0), SetResponseBody(httpie.BodyConf{}), SetIgnoreCodes(http.StatusRequestedRangeNotSatisfiable))
+	for attempt := 0; ; attempt++ {
+		var done bool
+		done, err = d.fetch(ctx, cl, rfs)
+		if done {
+			break
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errs.Wrap(ctxErr)
+		}
+		if attempt >= maxResume || !d.resumable(err) {
+			return err
+		}
+		if err = timeutil.Sleep(ctx, timeutil.BackOff(attempt, 500*time.Millisecond, 10*time.Second)); err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	if err = d.verify(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpPath)
+		_ = os.Remove(d.validatorPath)
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, conf.Path); err != nil {
+		return err
+	}
+	_ = os.Remove(d.validatorPath)
+	return nil
+}
+
+type downloader struct {
+	conf    *DownloadConf
+	f       *os.File
+	written int64
+	total   int64
+	hashes  map[string]hash.Hash
+
+	// validator is the ETag or Last-Modified of the content in f, it is kept
+	// in validatorPath so that later calls can resume too.
+	validator     string
+	validatorPath string
+}
+
+// restore continues from what a previous attempt left in the temporary file.
+func (d *downloader) restore() error {
+	d.resetHashes()
+	n, err := io.Copy(d.hashWriter(), d.f)
+	if err != nil {
+		return err
+	}
+	d.written = n
+	b, err := os.ReadFile(d.validatorPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	d.validator = string(b)
+	return nil
+}
+
+// setValidator remembers the validator of the content being written.
+func (d *downloader) setValidator(v string) error {
+	d.validator = v
+	if v == "" {
+		if err := os.Remove(d.validatorPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(d.validatorPath, []byte(v), 0o644)
+}
+
+// canResume tells whether appending to the partial content is safe, that is
+// a change upstream is either prevented by If-Range or caught by a checksum.
+func (d *downloader) canResume() bool {
+	return d.validator != "" || len(d.hashes) > 0
+}
+
+func (d *downloader) reset() error {
+	if err := d.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := d.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	d.written = 0
+	d.resetHashes()
+	return nil
+}
+
+func (d *downloader) resetHashes() {
+	d.hashes = map[string]hash.Hash{}
+	if d.conf.SHA256 != "" {
+		d.hashes["sha256"] = sha256.New()
+	}
+	if d.conf.MD5 != "" {
+		d.hashes["md5"] = md5.New()
+	}
+}
+
+func (d *downloader) hashWriter() io.Writer {
+	ws := []io.Writer{io.Discard}
+	for _, h := range d.hashes {
+		ws = append(ws, h)
+	}
+	return io.MultiWriter(ws...)
+}
+
+// fetch requests the remaining content and appends it to the file. It
+// returns true once the content is complete.
+func (d *downloader) fetch(ctx context.Context, cl *Client, rfs []RequestFunc) (bool, error) {
+	if d.written > 0 && !d.canResume() {
+		if err := d.reset(); err != nil {
+			return false, err
+		}
+	}
+	rfs = append([]RequestFunc{SetHeader("Accept", "*/*")}, rfs...)
+	rfs = append(rfs, SetDoNotParseResponse(), func(r *resty.Request) {
+		if d.written > 0 {
+			r.SetHeader("Range", fmt.Sprintf("bytes=%d-", d.written))
+			if d.validator != "" {
+				r.SetHeader("If-Range", d.validator)
+			}
+		}
+	})
+	res, err := cl.GetCtx(ctx, d.conf.URL, rfs...)
+	if res != nil && res.RawBody() != nil {
+		defer func() {
+			_ = res.RawBody().Close()
+		}()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch res.StatusCode() {
+	case http.StatusRequestedRangeNotSatisfiable:
+		if d.written == 0 {
+			return false, NewErrorFromRestyResponse(res)
+		}
+		// What we have already covers the whole content, unless the content
+		// has another length now, "bytes */total" tells.
+		if totalFromContentRange(res.Header().Get("Content-Range")) == d.written {
+			return true, nil
+		}
+		if err = d.reset(); err != nil {
+			return false, err
+		}
+		return false, errContentChanged
+	case http.StatusPartialContent:
+		d.total = totalFromContentRange(res.Header().Get("Content-Range"))
+	default:
+		// The server ignored the range or the content changed, start over.
+		if err = d.reset(); err != nil {
+			return false, err
+		}
+		if err = d.setValidator(validatorOf(res.Header())); err != nil {
+			return false, err
+		}
+		d.total = res.RawResponse.ContentLength
+	}
+
+	_, err = io.Copy(&progressWriter{d: d, w: io.MultiWriter(d.f, d.hashWriter())}, res.RawBody())
+	if err != nil {
+		return false, err
+	}
+	if d.total >= 0 && d.written < d.total {
+		return false, io.ErrUnexpectedEOF
+	}
+	return true, nil
+}
+
+// resumable tells whether the failure is worth another Range request.
+func (d *downloader) resumable(err error) bool {
+	if errs.IsCancelled(err) {
+		return false
+	}
+	var e *errorx.Error
+	if errors.As(err, &e) {
+		return e.Code >= http.StatusInternalServerError || e.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func (d *downloader) verify() error {
+	for name, want := range map[string]string{"sha256": d.conf.SHA256, "md5": d.conf.MD5} {
+		h, ok := d.hashes[name]
+		if !ok {
+			continue
+		}
+		got := hex.EncodeToString(h.Sum(nil))
+		if !strings.EqualFold(got, want) {
+			return errorx.New("http-Download", http.StatusUnprocessableEntity, "checksum mismatch").
+				WithMetadata(errorx.Metadata{"url": d.conf.URL, "algorithm": name, "want": want, "got": got})
+		}
+	}
+	return nil
+}
+
+type progressWriter struct {
+	d *downloader
+	w io.Writer
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.d.written += int64(n)
+	if pw.d.conf.Progress != nil {
+		pw.d.conf.Progress(pw.d.written, pw.d.total)
+	}
+	return n, err
+}
+
+// validatorOf returns what identifies the version of the content: a strong
+// ETag, or else Last-Modified. Weak ETags are not allowed in If-Range.
+func validatorOf(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// totalFromContentRange parses the complete length of "bytes 0-99/1000".
+func totalFromContentRange(v string) int64 {
+	i := strings.LastIndex(v, "/")
+	if i < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
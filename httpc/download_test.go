@@ -0,0 +1,138 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadFile_Resume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(content)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// Send half of the content then drop the connection.
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file")
+	var lastWritten, lastTotal int64
+	err := DownloadFile(context.Background(), &DownloadConf{
+		URL:    server.URL,
+		Path:   path,
+		SHA256: hex.EncodeToString(sum[:]),
+		Progress: func(written, total int64) {
+			lastWritten, lastTotal = written, total
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, int64(len(content)), lastWritten)
+	assert.Equal(t, int64(len(content)), lastTotal)
+
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+	_, err = os.Stat(path + partSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadFile_Charset(t *testing.T) {
+	// "中文" in GBK, which the API client would convert to utf-8.
+	content := []byte{0xd6, 0xd0, 0xce, 0xc4}
+	sum := sha256.Sum256(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=gbk")
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	for name, checksum := range map[string]string{"without checksum": "", "with checksum": hex.EncodeToString(sum[:])} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			err := DownloadFile(context.Background(), &DownloadConf{URL: server.URL, Path: path, SHA256: checksum})
+			assert.NoError(t, err)
+			got, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, content, got)
+		})
+	}
+}
+
+func TestDownloadFile_ChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file")
+	err := DownloadFile(context.Background(), &DownloadConf{URL: server.URL, Path: path, MD5: "0000"})
+	assert.Error(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadFile_ChangedUpstream(t *testing.T) {
+	content := []byte("new content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	for name, old := range map[string]string{
+		"if-range mismatch": "old",           // 200 with the new content.
+		"longer than total": "old content!!", // 416 with another total.
+	} {
+		path := filepath.Join(t.TempDir(), "file")
+		assert.NoError(t, os.WriteFile(path+partSuffix, []byte(old), 0o644))
+		validator := `"v1"`
+		if len(old) > len(content) {
+			validator = `"v2"`
+		}
+		assert.NoError(t, os.WriteFile(path+validatorSuffix, []byte(validator), 0o644))
+
+		err := DownloadFile(context.Background(), &DownloadConf{URL: server.URL, Path: path})
+		assert.NoError(t, err, name)
+		got, _ := os.ReadFile(path)
+		assert.Equal(t, content, got, name)
+		_, err = os.Stat(path + validatorSuffix)
+		assert.True(t, os.IsNotExist(err), name)
+	}
+}
+
+func TestDownloadFile_NoValidator(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader([]byte("new content")))
+	}))
+	defer server.Close()
+
+	// Without a validator nor a checksum, a leftover part can't be trusted.
+	path := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(path+partSuffix, []byte("old"), 0o644))
+	assert.NoError(t, DownloadFile(context.Background(), &DownloadConf{URL: server.URL, Path: path}))
+	assert.Equal(t, []string{""}, ranges)
+	got, _ := os.ReadFile(path)
+	assert.Equal(t, "new content", string(got))
+}
@@ -7,14 +7,15 @@ import (
 	"github.com/leclerc04/go-tool/errorx"
 )
 
+// NewErrorFromRestyResponse turns a failed response into an *errorx.Error
+// carrying its status code and body.
 func NewErrorFromRestyResponse(res *resty.Response) *errorx.Error {
-	switch res.StatusCode() {
-	case http.StatusConflict:
-
-	}
-	return errorx.New(
+	e := errorx.New(
 		http.StatusText(res.StatusCode()),
 		res.StatusCode(),
 		res.String())
-
+	if res.Request != nil {
+		e.WithMetadata(errorx.Metadata{"method": res.Request.Method, "url": res.Request.URL})
+	}
+	return e
 }
@@ -1,16 +1,16 @@
 package httpc
 
 import (
+	"context"
 	"io"
-	"log"
 	"net/http"
-	"strings"
+	"net/url"
+	"time"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/leclerc04/go-tool/errorx"
 
 	"github.com/zeromicro/go-zero/core/jsonx"
-
-	"github.com/avast/retry-go/v4"
 )
 
 func Stringify(v interface{}) string {
@@ -28,110 +28,124 @@ type FetchConf struct {
 	Data        any
 	Headers     map[string]string
 	AccessToken string
-}
+	Query       url.Values
+	Files       []FetchFile // Files 非空时以 multipart 提交, Data 需为 map[string]string
 
-const maxAttempt = 3
+	Timeout time.Duration   // Timeout 单次调用(含重试)的超时时间, 0 表示使用 Client 的超时
+	Ctx     context.Context // Ctx 用于 Fetch/FormFetch, FetchCtx/FormFetchCtx 以参数为准
 
-func Fetch(c *FetchConf) (contents []byte, err error) {
-	err = retry.Do(func() error {
-		body := ""
-		if c.Method == http.MethodPost {
-			body = Stringify(c.Data)
-		}
+	// ValidateStatus 判断响应状态码是否成功, 默认只接受 2xx.
+	ValidateStatus func(code int) bool
+	// Client 发送请求的客户端, 默认与其他 Fetch 调用共享同一个.
+	Client *Client
+}
 
-		request, err := http.NewRequest(c.Method, c.URL, strings.NewReader(body))
-		if err != nil {
-			return err
-		}
-		if c.Method == http.MethodPost {
-			request.Header.Add("Content-Type", "application/json;charset=utf-8")
-		}
+// FetchFile is a file part of a multipart FetchConf.
+type FetchFile struct {
+	Param    string
+	FileName string
+	Reader   io.Reader
+}
 
-		for k, v := range c.Headers {
-			request.Header.Add(k, v)
-		}
-		resp, err := http.DefaultClient.Do(request)
-		if err != nil {
-			httpCode := http.StatusInternalServerError
-			// 检查 resp 是否为 nil，确保不会在 defer 函数中发生 panic
-			if resp != nil {
-				httpCode = resp.StatusCode
-			}
-			return errorx.New("http-Client", httpCode, "发起HTTP请求异常").
-				WithMetadata(errorx.Metadata{"req": c}).
-				WithError(err)
-		}
+var defaultFetchClient = New()
 
-		defer func() {
-			if err = resp.Body.Close(); err != nil {
-				return
-			}
-		}()
+func Fetch(c *FetchConf) (contents []byte, err error) {
+	return FetchCtx(c.context(), c)
+}
 
-		if contents, err = io.ReadAll(resp.Body); err != nil {
-			return err
+// FetchCtx sends c through a httpc.Client, Data is sent as JSON except for
+// GET/HEAD requests. A response whose status is rejected by ValidateStatus is
+// returned as an *errorx.Error carrying the status code.
+func FetchCtx(ctx context.Context, c *FetchConf) (contents []byte, err error) {
+	rfs := []RequestFunc{SetHeader("Content-Type", "application/json;charset=utf-8")}
+	switch {
+	case len(c.Files) > 0:
+		data, ok := c.Data.(map[string]string)
+		if c.Data != nil && !ok {
+			return nil, invalidFetchData(c)
 		}
-
-		return nil
-	},
-		retry.Attempts(maxAttempt), // 重试3次
-		retry.OnRetry(func(n uint, err error) {
-			log.Printf("http请求发送失败，当前重试次数%d,error:%v\n", n, err.Error())
-		}),
-	)
-
-	return
+		rfs = append(rfs, SetFormData(data))
+		for _, f := range c.Files {
+			rfs = append(rfs, SetFileReader(f.Param, f.FileName, f.Reader))
+		}
+	case c.Method != http.MethodGet && c.Method != http.MethodHead && c.Data != nil:
+		rfs = append(rfs, SetBody(Stringify(c.Data)))
+	}
+	return c.fetch(ctx, rfs)
 }
 
 // FormFetch 表单提交
 func FormFetch(c *FetchConf) (contents []byte, err error) {
-	err = retry.Do(func() error {
-		strData, ok := c.Data.(string)
-		if !ok {
-			return err
-		}
-		request, err := http.NewRequest(c.Method, c.URL, strings.NewReader(strData))
-		if err != nil {
-			return err
-		}
-
-		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	return FormFetchCtx(c.context(), c)
+}
 
-		for k, v := range c.Headers {
-			request.Header.Add(k, v)
-		}
+// FormFetchCtx 表单提交, 请求及重试随 ctx 取消.
+// Data 支持已编码的 string, map[string]string 及 url.Values.
+func FormFetchCtx(ctx context.Context, c *FetchConf) (contents []byte, err error) {
+	rfs := []RequestFunc{SetHeader("Content-Type", "application/x-www-form-urlencoded")}
+	switch data := c.Data.(type) {
+	case string:
+		rfs = append(rfs, SetBody(data))
+	case map[string]string:
+		rfs = append(rfs, SetFormData(data))
+	case url.Values:
+		rfs = append(rfs, SetBody(data.Encode()))
+	default:
+		return nil, invalidFetchData(c)
+	}
+	return c.fetch(ctx, rfs)
+}
 
-		resp, err := http.DefaultClient.Do(request)
-		if err != nil {
-			httpCode := http.StatusInternalServerError
-			// 检查 resp 是否为 nil，确保不会在 defer 函数中发生 panic
-			if resp != nil {
-				httpCode = resp.StatusCode
-			}
-			return errorx.New("http-Client", httpCode, "发起HTTP请求异常").
-				WithMetadata(errorx.Metadata{"req": c}).
-				WithError(err)
-		}
+func (c *FetchConf) context() context.Context {
+	if c.Ctx != nil {
+		return c.Ctx
+	}
+	return context.Background()
+}
 
-		defer func() {
-			if err = resp.Body.Close(); err != nil {
-				log.Printf("close HTTP响应异常，入参:%v", c)
-				return
+func (c *FetchConf) fetch(ctx context.Context, rfs []RequestFunc) ([]byte, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	cl := c.Client
+	if cl == nil {
+		cl = defaultFetchClient
+	}
 
-			}
-		}()
+	if c.AccessToken != "" {
+		rfs = append(rfs, func(r *resty.Request) {
+			r.SetAuthToken(c.AccessToken)
+		})
+	}
+	if len(c.Query) > 0 {
+		rfs = append(rfs, SetQueryParamsFromValues(c.Query))
+	}
+	rfs = append(rfs, SetHeaders(c.Headers))
 
-		if contents, err = io.ReadAll(resp.Body); err != nil {
-			return err
+	res, err := cl.RequestCtx(ctx, c.Method, c.URL, rfs...)
+	if res == nil || res.StatusCode() == 0 {
+		return nil, err
+	}
+	validate := c.ValidateStatus
+	if validate == nil {
+		validate = isSuccess
+	}
+	if !validate(res.StatusCode()) {
+		if err == nil {
+			err = NewErrorFromRestyResponse(res)
 		}
+		return res.Body(), err
+	}
+	return res.Body(), nil
+}
 
-		return nil
-	},
-		retry.Attempts(maxAttempt), // 重试3次
-		retry.OnRetry(func(n uint, err error) {
-			log.Printf("HTTP请求失败, 当前重试次数: %d，入参：%v\n", n, c)
-		}),
-	)
+func isSuccess(code int) bool {
+	return code >= http.StatusOK && code < http.StatusMultipleChoices
+}
 
-	return
+func invalidFetchData(c *FetchConf) error {
+	return errorx.BadRequest("unsupported data type %T", c.Data).
+		WithMetadata(errorx.Metadata{"method": c.Method, "url": c.URL})
 }
@@ -0,0 +1,76 @@
+package httpc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			b, _ := io.ReadAll(r.Body)
+			_, _ = w.Write([]byte(r.Method + " " + r.URL.Query().Get("q") + " " + string(b)))
+		case "/form":
+			_ = r.ParseForm()
+			_, _ = w.Write([]byte(r.PostForm.Get("name")))
+		case "/upload":
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			b, _ := io.ReadAll(f)
+			_, _ = w.Write([]byte(r.FormValue("kind") + ":" + string(b)))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("boom"))
+		}
+	}))
+	defer server.Close()
+
+	b, err := Fetch(&FetchConf{
+		Method: http.MethodPost,
+		URL:    server.URL + "/echo",
+		Query:  url.Values{"q": []string{"x"}},
+		Data:   map[string]int{"a": 1},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `POST x {"a":1}`, string(b))
+
+	b, err = FormFetch(&FetchConf{Method: http.MethodPost, URL: server.URL + "/form", Data: url.Values{"name": []string{"tom"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "tom", string(b))
+
+	_, err = FormFetch(&FetchConf{Method: http.MethodPost, URL: server.URL + "/form", Data: 1})
+	assert.Error(t, err)
+
+	b, err = Fetch(&FetchConf{
+		Method: http.MethodPost,
+		URL:    server.URL + "/upload",
+		Data:   map[string]string{"kind": "scan"},
+		Files:  []FetchFile{{Param: "file", FileName: "a.txt", Reader: strings.NewReader("content")}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "scan:content", string(b))
+
+	b, err = Fetch(&FetchConf{Method: http.MethodGet, URL: server.URL + "/fail", Client: New(SetRetryCount(0))})
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, http.StatusInternalServerError, e.Code)
+	assert.Equal(t, "boom", string(b))
+
+	_, err = Fetch(&FetchConf{Method: http.MethodGet, URL: server.URL + "/slow", Timeout: 50 * time.Millisecond})
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package httpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/base/trace"
+)
+
+var (
+	metricHedgesTotal = mon.NewCounterVec(
+		"httpc", "hedges_total", "Number of hedged requests sent, by whether they won the race.",
+		[]string{"name", "result"})
+	metricHedgeDelay = mon.NewGaugeVec(
+		"httpc", "hedge_delay_seconds", "Current delay before sending a hedged request.",
+		[]string{"name"})
+)
+
+// HedgeConf configures a Hedger.
+type HedgeConf struct {
+	// Delay is how long to wait for a response before sending a hedge. With
+	// Percentile, it is used until enough latencies are known, and requests
+	// are not hedged until then if it is zero.
+	Delay time.Duration
+	// Percentile, between 0 and 1, hedges requests slower than that
+	// percentile of the recent latencies, e.g. 0.95.
+	Percentile float64
+	// MaxHedges is the number of extra requests sent for a request, 1 if zero.
+	MaxHedges int
+}
+
+const (
+	// hedgeSamples is the number of recent latencies kept for Percentile.
+	hedgeSamples = 1000
+	// hedgeMinSamples is the number of latencies needed to use Percentile.
+	hedgeMinSamples = 100
+)
+
+// Hedger sends idempotent requests again when they are slower than usual, and
+// takes the first successful response. It trades extra load on the upstream
+// for a lower tail latency, so watch httpc_hedges_total when tuning it.
+// Requests whose body can't be sent twice, or whose response is read with
+// SetDoNotParseResponse of httpc, are not hedged.
+type Hedger struct {
+	name string
+	conf HedgeConf
+
+	mu        sync.Mutex
+	latencies []time.Duration // latencies is a ring buffer of recent latencies.
+	next      int
+	delay     time.Duration
+	measured  bool // measured is true once delay is the percentile.
+}
+
+// NewHedger creates a Hedger, name is used as metric label.
+func NewHedger(name string, conf HedgeConf) *Hedger {
+	if conf.MaxHedges <= 0 {
+		conf.MaxHedges = 1
+	}
+	metricHedgeDelay.With(mon.Labels{"name": name}).Set(conf.Delay.Seconds())
+	return &Hedger{name: name, conf: conf, delay: conf.Delay}
+}
+
+// Delay returns how long to wait before sending a hedge.
+func (h *Hedger) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay
+}
+
+// hedgeDelay returns Delay, and false while requests must not be hedged.
+func (h *Hedger) hedgeDelay() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay, h.conf.Percentile <= 0 || h.conf.Delay > 0 || h.measured
+}
+
+func (h *Hedger) observe(latency time.Duration) {
+	if h.conf.Percentile <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, latency)
+	} else {
+		h.latencies[h.next] = latency
+	}
+	h.next = (h.next + 1) % hedgeSamples
+	// Sorting on every request would be wasteful, the percentile moves slowly.
+	if len(h.latencies) >= hedgeMinSamples && h.next%(hedgeMinSamples/10) == 0 {
+		sorted := append([]time.Duration(nil), h.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		h.delay = sorted[int(float64(len(sorted)-1)*h.conf.Percentile)]
+		h.measured = true
+		metricHedgeDelay.With(mon.Labels{"name": h.name}).Set(h.delay.Seconds())
+	}
+}
+
+type hedgeResult struct {
+	index   int
+	r       *resty.Request
+	res     *resty.Response
+	err     error
+	latency time.Duration
+}
+
+// SetDoNotParseResponse leaves the response bodies of every request for the
+// caller to read and close, see resty.Client.SetDoNotParseResponse. Such
+// requests are not hedged.
+func (c *Client) SetDoNotParseResponse(parse bool) *Client {
+	c.doNotParseResponse = parse
+	c.Client.SetDoNotParseResponse(parse)
+	return c
+}
+
+// streamsResponse tells whether the response of r is left for the caller to
+// read. The body of a losing hedge would leak then.
+func (c *Client) streamsResponse(r *resty.Request) bool {
+	return c.doNotParseResponse || doNotParseKey.Value(r.Context())
+}
+
+// executeHedged sends the request and, if it is idempotent and slow, hedges
+// it. It returns the first successful attempt, or the last failed one.
+func (c *Client) executeHedged(ctx context.Context, rfs []RequestFunc, method, url string) (*resty.Request, *resty.Response, error) {
+	h := c.Hedger
+	first := c.newRequest(ctx, rfs)
+	if !IsIdempotent(method, first.Header) || !replayable(first) || c.streamsResponse(first) {
+		res, err := c.execute(ctx, first, method, url)
+		return first, res, err
+	}
+	delay, ok := h.hedgeDelay()
+	if !ok {
+		// Learn the latencies before hedging.
+		start := time.Now()
+		res, err := c.execute(ctx, first, method, url)
+		if err == nil && (!res.IsError() || c.IgnoreCodes.Has(res.StatusCode())) {
+			h.observe(time.Since(start))
+		}
+		return first, res, err
+	}
+
+	results := make(chan hedgeResult, h.conf.MaxHedges+1)
+	var cancels []context.CancelFunc
+	launch := func(r *resty.Request, actx context.Context, cancel context.CancelFunc) {
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			start := time.Now()
+			res, err := c.execute(actx, r, method, url)
+			results <- hedgeResult{index: index, r: r, res: res, err: err, latency: time.Since(start)}
+		}()
+	}
+	// Keep the values set on the context by rfs.
+	actx, cancel := context.WithCancel(first.Context())
+	launch(first.SetContext(actx), actx, cancel)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) > h.conf.MaxHedges {
+				continue
+			}
+			trace.Printc(ctx, "http hedge", "delay", delay)
+			actx, cancel := context.WithCancel(ctx)
+			launch(c.newRequest(actx, rfs), actx, cancel)
+			pending++
+			if len(cancels) <= h.conf.MaxHedges {
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			pending--
+			ok := result.err == nil && (!result.res.IsError() || c.IgnoreCodes.Has(result.res.StatusCode()))
+			if !ok && pending > 0 {
+				// Another attempt may still succeed.
+				continue
+			}
+			if ok {
+				h.observe(result.latency)
+			}
+			// Streamed responses are not hedged, so the winner's body is
+			// already read too.
+			for _, cancel := range cancels {
+				cancel()
+			}
+			if hedges := len(cancels) - 1; hedges > 0 {
+				won := 0
+				if result.index > 0 {
+					won = 1
+				}
+				metricHedgesTotal.With(mon.Labels{"name": h.name, "result": "won"}).Add(float64(won))
+				metricHedgesTotal.With(mon.Labels{"name": h.name, "result": "lost"}).Add(float64(hedges - won))
+			}
+			return result.r, result.res, result.err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package httpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedger(t *testing.T) {
+	var calls, cancelled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				atomic.AddInt32(&cancelled, 1)
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	h := NewHedger("test", HedgeConf{Delay: 20 * time.Millisecond})
+	won := metricHedgesTotal.With(mon.Labels{"name": "test", "result": "won"})
+	wonBefore := testutil.ToFloat64(won)
+	c := New(SetHedger(h))
+
+	start := time.Now()
+	res, err := c.GetCtx(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "fast", res.String())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1.0, testutil.ToFloat64(won)-wonBefore)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&cancelled) == 1 }, time.Second, 10*time.Millisecond)
+
+	// POST is not idempotent, it must not be sent twice.
+	atomic.StoreInt32(&calls, 1)
+	res, err = c.PostCtx(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
+
+func TestHedger_Bodies(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+	c := New(SetHedger(NewHedger("test-bodies", HedgeConf{Delay: 10 * time.Millisecond})))
+
+	streamed := New(SetHedger(NewHedger("test-bodies-streamed", HedgeConf{Delay: 10 * time.Millisecond})))
+	streamed.SetDoNotParseResponse(true)
+
+	tests := []struct {
+		name     string
+		c        *Client
+		rfs      []RequestFunc
+		expected []string
+	}{
+		{"replayable body", c, []RequestFunc{SetBody(strings.NewReader("payload"))}, []string{"payload", "payload"}},
+		{"reader body", c, []RequestFunc{func(r *resty.Request) { r.SetBody(strings.NewReader("payload")) }}, []string{"payload"}},
+		{"streamed response", c, []RequestFunc{SetDoNotParseResponse()}, []string{""}},
+		{"streamed client", streamed, nil, []string{""}},
+	}
+	for _, tt := range tests {
+		bodies = nil
+		res, err := tt.c.PutCtx(context.Background(), server.URL, tt.rfs...)
+		require.NoError(t, err, tt.name)
+		if res.RawBody() != nil {
+			_ = res.RawBody().Close()
+		}
+		time.Sleep(60 * time.Millisecond) // Let the losing hedge finish.
+		mu.Lock()
+		assert.Equal(t, tt.expected, bodies, tt.name)
+		mu.Unlock()
+	}
+}
+
+func TestHedger_Percentile(t *testing.T) {
+	h := NewHedger("test-percentile", HedgeConf{Delay: time.Second, Percentile: 0.9})
+	for i := 1; i < hedgeMinSamples; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, time.Second, h.Delay())
+	h.observe(hedgeMinSamples * time.Millisecond)
+	assert.Equal(t, 90*time.Millisecond, h.Delay())
+}
+
+func TestHedger_PercentileWithoutDelay(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// Requests are not hedged until the percentile is known.
+	h := NewHedger("test-percentile-no-delay", HedgeConf{Percentile: 0.9})
+	c := New(SetHedger(h))
+	for i := 0; i < hedgeMinSamples; i++ {
+		_, err := c.GetCtx(context.Background(), server.URL)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, hedgeMinSamples, atomic.LoadInt32(&calls))
+	assert.GreaterOrEqual(t, h.Delay(), 20*time.Millisecond)
+}
@@ -0,0 +1,104 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/errorx"
+)
+
+// envelope mirrors Response, but keeps data raw so that it can be decoded
+// into the caller's type.
+type envelope struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// CallJSON sends the request through c, expects a Response envelope in the
+// body and decodes its data into T. A code other than 200 in the envelope is
+// returned as an *errorx.Error carrying that code and message.
+func CallJSON[T any](ctx context.Context, c *Client, method, url string, rfs ...RequestFunc) (T, error) {
+	var data T
+	res, err := c.RequestCtx(ctx, method, url, rfs...)
+	if err != nil {
+		// Error responses may still carry an envelope explaining the failure,
+		// problem details are already decoded.
+		var httpErr *errorx.Error
+		if res != nil && errors.As(err, &httpErr) && !isProblem(res) {
+			var env envelope
+			if json.Unmarshal(res.Body(), &env) == nil && env.Code != 0 {
+				return data, envelopeError(res, env)
+			}
+		}
+		return data, err
+	}
+
+	// StrictJSON only applies to data, upstreams may add envelope fields.
+	var env envelope
+	if err = json.Unmarshal(res.Body(), &env); err != nil {
+		return data, decodeError(res, err)
+	}
+	if env.Code != http.StatusOK {
+		return data, envelopeError(res, env)
+	}
+	if len(env.Data) == 0 || bytes.Equal(env.Data, []byte("null")) {
+		return data, nil
+	}
+	if err = c.decodeJSON(env.Data, &data); err != nil {
+		return data, decodeError(res, err)
+	}
+	return data, nil
+}
+
+func GetJSON[T any](ctx context.Context, url string, rfs ...RequestFunc) (T, error) {
+	return CallJSON[T](ctx, New(), resty.MethodGet, url, rfs...)
+}
+
+func PostJSON[T any](ctx context.Context, url string, body any, rfs ...RequestFunc) (T, error) {
+	return CallJSON[T](ctx, New(), resty.MethodPost, url, append([]RequestFunc{SetBody(body)}, rfs...)...)
+}
+
+func PutJSON[T any](ctx context.Context, url string, body any, rfs ...RequestFunc) (T, error) {
+	return CallJSON[T](ctx, New(), resty.MethodPut, url, append([]RequestFunc{SetBody(body)}, rfs...)...)
+}
+
+func PatchJSON[T any](ctx context.Context, url string, body any, rfs ...RequestFunc) (T, error) {
+	return CallJSON[T](ctx, New(), resty.MethodPatch, url, append([]RequestFunc{SetBody(body)}, rfs...)...)
+}
+
+func DeleteJSON[T any](ctx context.Context, url string, rfs ...RequestFunc) (T, error) {
+	return CallJSON[T](ctx, New(), resty.MethodDelete, url, rfs...)
+}
+
+func (c *Client) decodeJSON(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if c.StrictJSON {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+func envelopeError(res *resty.Response, env envelope) *errorx.Error {
+	return errorx.New("http-Response", env.Code, env.Msg).
+		WithMetadata(errorx.Metadata{
+			"method": res.Request.Method,
+			"url":    res.Request.URL,
+			"status": res.StatusCode(),
+		})
+}
+
+func decodeError(res *resty.Response, err error) *errorx.Error {
+	return errorx.New("http-Response", http.StatusBadGateway, "invalid response body").
+		WithMetadata(errorx.Metadata{
+			"method": res.Request.Method,
+			"url":    res.Request.URL,
+			"status": res.StatusCode(),
+			"body":   res.String(),
+		}).
+		WithError(err)
+}
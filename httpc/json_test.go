@@ -0,0 +1,66 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonUser struct {
+	Name string `json:"name"`
+}
+
+func newJSONServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestGetJSON(t *testing.T) {
+	server := newJSONServer(http.StatusOK, `{"code":200,"msg":"ok","data":{"name":"tom","age":3}}`)
+	defer server.Close()
+
+	user, err := GetJSON[jsonUser](context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "tom", user.Name)
+
+	_, err = CallJSON[jsonUser](context.Background(), New(SetStrictJSON()), resty.MethodGet, server.URL)
+	assert.Error(t, err)
+
+	// Unknown envelope fields are fine with StrictJSON.
+	server = newJSONServer(http.StatusOK, `{"code":200,"msg":"ok","request_id":"r1","data":{"name":"tom"}}`)
+	defer server.Close()
+	user, err = CallJSON[jsonUser](context.Background(), New(SetStrictJSON()), resty.MethodGet, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "tom", user.Name)
+}
+
+func TestGetJSON_EnvelopeError(t *testing.T) {
+	server := newJSONServer(http.StatusOK, `{"code":404001,"msg":"user not found","data":null}`)
+	defer server.Close()
+
+	_, err := GetJSON[jsonUser](context.Background(), server.URL)
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, 404001, e.Code)
+	assert.Equal(t, "user not found", e.Msg)
+}
+
+func TestPostJSON_HTTPErrorWithEnvelope(t *testing.T) {
+	server := newJSONServer(http.StatusBadRequest, `{"code":400,"msg":"bad name","data":null}`)
+	defer server.Close()
+
+	_, err := PostJSON[jsonUser](context.Background(), server.URL, jsonUser{Name: "tom"})
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, 400, e.Code)
+	assert.Equal(t, "bad name", e.Msg)
+}
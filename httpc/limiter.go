@@ -0,0 +1,201 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/concurrent"
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/base/trace"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+)
+
+// DefaultRetryAfter is how long an upstream is paused after a 429 response
+// without a Retry-After header.
+const DefaultRetryAfter = time.Second
+
+var (
+	metricLimiterWaitSeconds = mon.NewHistogramVec(
+		"httpc", "rate_limiter_wait_seconds", "Time spent waiting for rate limiters.",
+		[]float64{.001, .01, .1, .5, 1, 5, 30}, []string{"name"})
+	metricLimiterThrottledTotal = mon.NewCounterVec(
+		"httpc", "rate_limiter_throttled_total", "Number of 429 responses received by rate limiters.",
+		[]string{"name"})
+)
+
+// LimitKey decides what a RateLimiter applies a limit to.
+type LimitKey int
+
+const (
+	// LimitByHost applies one limit per upstream host.
+	LimitByHost LimitKey = iota
+	// LimitByBaseURI applies one limit per host and Client.BaseURI.
+	LimitByBaseURI
+)
+
+// LimitConf configures a RateLimiter. Zero values disable the related limit.
+type LimitConf struct {
+	QPS         float64 // QPS is the sustained number of requests per second.
+	Burst       int     // Burst is the number of requests allowed at once, at least 1.
+	MaxInflight int64   // MaxInflight caps the number of concurrent requests.
+	Key         LimitKey
+}
+
+// RateLimiter throttles requests to upstreams with a token bucket and a
+// concurrency cap, and pauses an upstream when it answers 429. A RateLimiter
+// can be shared by several clients.
+type RateLimiter struct {
+	name string
+	conf LimitConf
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	sem *concurrent.Semaphore
+
+	mu           sync.Mutex
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// NewRateLimiter creates a RateLimiter, name is used as metric label.
+func NewRateLimiter(name string, conf LimitConf) *RateLimiter {
+	if conf.Burst <= 0 {
+		conf.Burst = 1
+	}
+	return &RateLimiter{
+		name:    name,
+		conf:    conf,
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (l *RateLimiter) bucket(key string) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.conf.Burst)}
+		if l.conf.MaxInflight > 0 {
+			b.sem = concurrent.NewSemaphore(l.name+":"+key, l.conf.MaxInflight)
+		}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// Wait blocks until a request to key may be sent or ctx is done. On success,
+// the returned func must be called once the request is finished.
+func (l *RateLimiter) Wait(ctx context.Context, key string) (func(), error) {
+	start := time.Now()
+	defer func() {
+		metricLimiterWaitSeconds.With(mon.Labels{"name": l.name}).Observe(time.Since(start).Seconds())
+	}()
+
+	b := l.bucket(key)
+	if b.sem != nil {
+		if err := b.sem.Acquire(ctx, 1); err != nil {
+			return func() {}, err
+		}
+	}
+	release := func() {
+		if b.sem != nil {
+			b.sem.Release(1)
+		}
+	}
+	for {
+		delay := b.reserve(l.conf)
+		if delay <= 0 {
+			return release, nil
+		}
+		trace.Printf(ctx, "rate limited: %s %s, wait %v", l.name, key, delay)
+		select {
+		case <-ctx.Done():
+			release()
+			return func() {}, errs.Wrap(ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Pause stops sending requests to key for d.
+func (l *RateLimiter) Pause(key string, d time.Duration) {
+	b := l.bucket(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// reserve takes a token, or returns how long to wait before trying again.
+func (b *bucket) reserve(conf LimitConf) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	if conf.QPS <= 0 {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * conf.QPS
+		if b.tokens > float64(conf.Burst) {
+			b.tokens = float64(conf.Burst)
+		}
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / conf.QPS * float64(time.Second))
+}
+
+// throttled tells whether res asks the client to slow down, and for how long.
+func throttled(res *resty.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	code := res.StatusCode()
+	retryAfter := res.Header().Get("Retry-After")
+	if code != http.StatusTooManyRequests && (code != http.StatusServiceUnavailable || retryAfter == "") {
+		return 0, false
+	}
+	return parseRetryAfter(retryAfter), true
+}
+
+// parseRetryAfter parses both forms of the Retry-After header.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return DefaultRetryAfter
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(time.Now()); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return DefaultRetryAfter
+}
+
+// limitKey returns the key of the limiter bucket for a request to rawURL.
+func (c *Client) limitKey(rawURL string) string {
+	host := c.requestHost(rawURL)
+	if c.Limiter.conf.Key == LimitByBaseURI {
+		return host + c.BaseURI
+	}
+	return host
+}
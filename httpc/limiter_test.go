@@ -0,0 +1,82 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_QPS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cl := New(SetRateLimiter(NewRateLimiter("test_qps", LimitConf{QPS: 20, Burst: 1})))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := cl.Get(server.URL)
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cl = New(SetRateLimiter(NewRateLimiter("test_qps_cancel", LimitConf{QPS: 0.1, Burst: 1})))
+	_, err := cl.GetCtx(ctx, server.URL)
+	assert.NoError(t, err)
+	_, err = cl.GetCtx(ctx, server.URL)
+	assert.Equal(t, context.DeadlineExceeded, errs.Unwrap(err))
+}
+
+func TestRateLimiter_MaxInflight(t *testing.T) {
+	var inflight, maxInflight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			m := maxInflight.Load()
+			if n <= m || maxInflight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	cl := New(SetRateLimiter(NewRateLimiter("test_inflight", LimitConf{MaxInflight: 2})))
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cl.Get(server.URL)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), maxInflight.Load())
+}
+
+func TestRateLimiter_RetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	cl := New(SetRateLimiter(NewRateLimiter("test_retry_after", LimitConf{})))
+	start := time.Now()
+	_, err := cl.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), hits.Load())
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
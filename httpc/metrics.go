@@ -0,0 +1,114 @@
+package httpc
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/base/trace"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+)
+
+var (
+	metricRequestsTotal = mon.NewCounterVec(
+		"httpc", "requests_total", "Number of http requests sent.",
+		[]string{"host", "method", "path", "code"})
+	metricRequestDuration = mon.NewHistogramVec(
+		"httpc", "request_duration_seconds", "Latency of http requests, including retries.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		[]string{"host", "method", "path"})
+	metricRetriesTotal = mon.NewCounterVec(
+		"httpc", "retries_total", "Number of http requests retried.",
+		[]string{"host", "method", "path"})
+)
+
+// PathTemplater maps the path of a request to the path label of metrics.
+// It must return a bounded set of values, for example "/users/{id}" rather
+// than "/users/42".
+type PathTemplater func(method, path string) string
+
+var idSegmentPattern = regexp.MustCompile(
+	`^(\d+|[0-9a-fA-F]{16,}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// DefaultPathTemplater keeps resty path params such as "/users/{id}" as is,
+// and replaces numeric, hex and uuid segments with "{id}".
+func DefaultPathTemplater(method, path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if idSegmentPattern.MatchString(seg) {
+			segs[i] = "{id}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// observer records metrics and trace of one call of RequestCtx.
+type observer struct {
+	ctx     context.Context
+	labels  mon.Labels
+	start   time.Time
+	retries int
+	done    func(error)
+}
+
+func (c *Client) observe(ctx context.Context, method, rawURL string) *observer {
+	host, path := c.requestHost(rawURL), rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+	templater := c.PathTemplater
+	if templater == nil {
+		templater = DefaultPathTemplater
+	}
+	return &observer{
+		ctx:    ctx,
+		labels: mon.Labels{"host": host, "method": method, "path": templater(method, path)},
+		start:  time.Now(),
+		done:   trace.Region(ctx, "http", "method", method, "url", rawURL),
+	}
+}
+
+// attempt accounts for one execution, which includes resty's own retries.
+func (o *observer) attempt(res *resty.Response) {
+	if res != nil && res.Request != nil && res.Request.Attempt > 1 {
+		o.retries += res.Request.Attempt - 1
+	}
+}
+
+// retry accounts for a retry decided outside of resty.
+func (o *observer) retry() {
+	o.retries++
+}
+
+func (o *observer) finish(res *resty.Response, err error) {
+	code := "error"
+	switch {
+	case res != nil && res.StatusCode() != 0:
+		code = strconv.Itoa(res.StatusCode())
+	case errs.IsCancelled(err):
+		code = "cancelled"
+	case httpie.IsTimeout(errs.Unwrap(err)) || errs.Unwrap(err) == context.DeadlineExceeded:
+		code = "timeout"
+	}
+	duration := time.Since(o.start)
+
+	metricRequestsTotal.With(mon.Labels{
+		"host":   o.labels["host"],
+		"method": o.labels["method"],
+		"path":   o.labels["path"],
+		"code":   code,
+	}).Inc()
+	metricRequestDuration.With(o.labels).Observe(duration.Seconds())
+	if o.retries > 0 {
+		metricRetriesTotal.With(o.labels).Add(float64(o.retries))
+	}
+
+	trace.Printc(o.ctx, "http response", "code", code, "retries", o.retries, "duration", duration)
+	o.done(err)
+}
@@ -0,0 +1,33 @@
+package httpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPathTemplater(t *testing.T) {
+	assert.Equal(t, "/api/users/{id}/orders", DefaultPathTemplater("GET", "/api/users/42/orders"))
+	assert.Equal(t, "/files/{id}", DefaultPathTemplater("GET", "/files/0f8fad5b-d9cb-469f-a165-70867728950e"))
+	assert.Equal(t, "/users/{id}", DefaultPathTemplater("GET", "/users/{id}"))
+	assert.Equal(t, "/v1/login", DefaultPathTemplater("POST", "/v1/login"))
+}
+
+func TestRequestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	_, err := Get(server.URL + "/users/7")
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricRequestsTotal.With(mon.Labels{
+		"host": u.Host, "method": http.MethodGet, "path": "/users/{id}", "code": "404",
+	})))
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logc"
+)
+
+// AccessLog logs each request with its status, size and duration. Server
+// errors are logged as errors. Put it after RequestID for the logs to carry
+// the request ID.
+func AccessLog() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := wrapWriter(w)
+			defer func() {
+				status := rw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				fields := []logc.LogField{
+					logc.Field("method", r.Method),
+					logc.Field("path", r.URL.Path),
+					logc.Field("query", r.URL.RawQuery),
+					logc.Field("status", status),
+					logc.Field("size", rw.size),
+					logc.Field("duration", time.Since(start).String()),
+					logc.Field("remote", r.RemoteAddr),
+					logc.Field("user_agent", r.UserAgent()),
+				}
+				if status >= http.StatusInternalServerError {
+					logc.Errorw(r.Context(), "http request", fields...)
+				} else {
+					logc.Infow(r.Context(), "http request", fields...)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConf is a cross-origin resource sharing policy.
+type CORSConf struct {
+	// AllowOrigins are the origins allowed, like "https://example.com",
+	// "https://*.example.com" for its subdomains, or "*" for any.
+	AllowOrigins []string
+	// AllowMethods defaults to GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowMethods []string
+	// AllowHeaders defaults to the headers requested by the preflight.
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// AllowCredentials lets browsers send cookies. The origin is then
+	// echoed rather than "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// CORS applies conf: it answers preflight requests of allowed origins and
+// adds the CORS headers to their other requests. Requests of other origins
+// are served without them, so browsers block the response.
+func CORS(conf CORSConf) Middleware {
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	anyOrigin := false
+	for _, o := range conf.AllowOrigins {
+		anyOrigin = anyOrigin || o == "*"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if origin == "" || !conf.allowed(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin && !conf.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if conf.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				if exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				h.Set("Access-Control-Allow-Headers", requested)
+			}
+			if conf.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(conf.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (conf *CORSConf) allowed(origin string) bool {
+	for _, o := range conf.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		// https://*.example.com matches https://a.example.com.
+		if scheme, domain, ok := strings.Cut(o, "*."); ok {
+			rest, ok := strings.CutPrefix(strings.ToLower(origin), strings.ToLower(scheme))
+			if ok && strings.HasSuffix(rest, "."+strings.ToLower(domain)) {
+				return true
+			}
+		}
+	}
+	return false
+}
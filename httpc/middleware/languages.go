@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/leclerc04/go-tool/agl/util/i18n"
+)
+
+// Languages stores the languages of the Accept-Language header in the
+// context, see i18n.WithLanguages, so that messages answered without the
+// request, e.g. by httpc.RespSuccess, follow them too. Languages set by an
+// outer middleware, e.g. from the settings of the user, are kept.
+func Languages() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(i18n.Languages(r.Context())) == 0 {
+				if langs := i18n.RequestLanguages(r); len(langs) > 0 {
+					r = r.WithContext(i18n.WithLanguages(r.Context(), langs...))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/leclerc04/go-tool/httpc"
+)
+
+// Timeout cancels the context of requests after d, handlers must stop then
+// and their context.DeadlineExceeded errors are answered with 504 by
+// httpc.RespError. Server-sent events streams are not limited.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// BodyLimit answers 413 to requests whose body is larger than n bytes.
+// Bodies without Content-Length fail when reading past n.
+func BodyLimit(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				httpc.RespError(w, r, errorx.New("http-BodyLimit", http.StatusRequestEntityTooLarge,
+					http.StatusText(http.StatusRequestEntityTooLarge)).
+					WithMetadata(errorx.Metadata{"limit": n, "size": r.ContentLength}).Show())
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
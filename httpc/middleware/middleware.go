@@ -0,0 +1,103 @@
+// Package middleware holds the HTTP middlewares shared by services: panic
+// recovery, request IDs, languages, access logs, CORS, timeouts and body
+// limits.
+//
+// Middlewares wrap net/http handlers, use Middleware.Zero or rest.ToMiddleware
+// to add them to a go-zero server:
+//
+//	server.Use(middleware.Chain(middleware.RequestID(), middleware.Languages(), middleware.Recover()).Zero())
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest"
+)
+
+// Middleware wraps a handler.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain returns a middleware applying ms in order, the first one being the
+// outermost.
+func Chain(ms ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(ms) - 1; i >= 0; i-- {
+			next = ms[i](next)
+		}
+		return next
+	}
+}
+
+// Zero converts m into a go-zero middleware.
+func (m Middleware) Zero() rest.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return m(next).ServeHTTP
+	}
+}
+
+// FromZero converts a go-zero middleware.
+func FromZero(m rest.Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		return m(next.ServeHTTP)
+	}
+}
+
+// responseWriter records the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func wrapWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// written tells whether the response was started.
+func (w *responseWriter) written() bool {
+	return w.status != 0
+}
+
+// Flush keeps streaming responses like server-sent events working.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("middleware: response writer doesn't support hijacking")
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/util/i18n"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/leclerc04/go-tool/httpc"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestRecover(t *testing.T) {
+	h := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":500`)
+
+	// A started response is left as is.
+	h = Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "partial", w.Body.String())
+
+	h = Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestRecover_ReportsOnce(t *testing.T) {
+	defer func(f func(context.Context, error)) { reportError = f }(reportError)
+	var reported int
+	reportError = func(ctx context.Context, err error) {
+		reported++
+	}
+	defer httpc.SetRespConf(httpc.GetRespConf())
+
+	panics := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	started := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	}))
+	for _, tc := range []struct {
+		report   bool
+		h        http.Handler
+		reported int
+	}{
+		{false, panics, 1},
+		// RespError reports it.
+		{true, panics, 0},
+		{true, started, 1},
+	} {
+		reported = 0
+		httpc.SetRespConf(httpc.RespConf{Report: tc.report})
+		tc.h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, tc.reported, reported)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var downstream string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Get(httpc.RequestIDHeader)
+	}))
+	defer server.Close()
+
+	var got string
+	h := Chain(RequestID(), AccessLog())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = httpc.RequestID(r.Context())
+		_, err := httpc.New().GetCtx(r.Context(), server.URL)
+		assert.NoError(t, err)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(httpc.RequestIDHeader, "abc-123")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "abc-123", got)
+	assert.Equal(t, "abc-123", downstream)
+	assert.Equal(t, "abc-123", w.Header().Get(httpc.RequestIDHeader))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(httpc.RequestIDHeader, "bad id\n")
+	h.ServeHTTP(w, r)
+	assert.NotEqual(t, "bad id\n", got)
+	assert.NotEmpty(t, got)
+	assert.Equal(t, got, w.Header().Get(httpc.RequestIDHeader))
+}
+
+func TestLanguages(t *testing.T) {
+	h := Languages()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpc.RespSuccess(r.Context(), w, nil)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	h.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `"msg":"`+errorx.CodeSuccess.Localize([]language.Tag{language.English}, nil)+`"`)
+
+	// Languages set earlier win over the header.
+	w = httptest.NewRecorder()
+	r = r.WithContext(i18n.WithLanguages(r.Context(), language.Chinese))
+	h.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `"msg":"`+errorx.CodeSuccess.Localize([]language.Tag{language.Chinese}, nil)+`"`)
+}
+
+func TestCORS(t *testing.T) {
+	h := CORS(CORSConf{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set("Origin", "https://a.example.org")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://a.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"))
+
+	for _, origin := range []string{"https://evil.com", "https://example.org.evil.com", "http://a.example.org"} {
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", origin)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		httpc.RespError(w, r, r.Context().Err())
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, w.Body.String(), `"code":504`)
+
+	h = Timeout(time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.False(t, ok)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/event-stream")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestBodyLimit(t *testing.T) {
+	h := BodyLimit(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := httpc.Bind(r, &req); err != nil {
+			httpc.RespError(w, r, err)
+			return
+		}
+		_, _ = io.WriteString(w, req.Name)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "bob"}`)))
+	assert.Contains(t, w.Body.String(), `"code":413`)
+
+	// Without Content-Length, the limit is hit while decoding.
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(`{"name": "bob"}`)))
+	r.ContentLength = -1
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `"code":413`)
+}
+
+func TestZero(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(mark("a"), FromZero(mark("b").Zero()))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"a", "b", "handler"}, order)
+}
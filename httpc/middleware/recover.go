@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/leclerc04/go-tool/agl/base/sentry"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/httpc"
+)
+
+// Recover reports panics of the handler to sentry, tagged with the request,
+// and answers them with httpc.RespError unless the response was started.
+// Panics are reported once, by RespError if its conf reports 5xx errors.
+// http.ErrAbortHandler is panicked again to abort the response silently.
+func Recover() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := wrapWriter(w)
+			err := serveRecover(next, rw, r)
+			if err != nil && !rw.written() {
+				httpc.RespError(rw, r, err)
+			}
+		})
+	}
+}
+
+func serveRecover(next http.Handler, w *responseWriter, r *http.Request) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			err = errs.Newf("Recover panic: %v", v)
+			if w.written() || !httpc.GetRespConf().Report {
+				ctx := sentry.WithTags(r.Context(), &sentry.Tags{Request: r})
+				reportError(ctx, err)
+			}
+		}
+	}()
+	next.ServeHTTP(w, r)
+	return nil
+}
+
+// reportError is replaced in tests.
+var reportError = func(ctx context.Context, err error) {
+	sentry.ErrorDepth(ctx, 2, err)
+}
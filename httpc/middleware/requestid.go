@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/leclerc04/go-tool/agl/util/strs"
+	"github.com/leclerc04/go-tool/httpc"
+)
+
+// maxRequestIDLen bounds the IDs accepted from clients.
+const maxRequestIDLen = 128
+
+// RequestID reuses the X-Request-Id header of the request, or generates
+// one, sets it on the response and in the context, see httpc.RequestID.
+// Logs of the context and requests sent by httpc clients carry it.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(httpc.RequestIDHeader)
+			if !validRequestID(id) {
+				id = strs.GenerateRandomStringURLSafe(16)
+			}
+			w.Header().Set(httpc.RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(httpc.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,281 @@
+package httpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/util/contextutil"
+	"github.com/leclerc04/go-tool/agl/util/pipe"
+)
+
+// MultipartFile is a file part of a MultipartConf, read from Reader or Path.
+type MultipartFile struct {
+	Param       string
+	FileName    string
+	ContentType string // ContentType is application/octet-stream if empty.
+
+	Reader io.Reader
+	// Path is opened when the body is sent if Reader is nil. Unlike a
+	// Reader, it can be sent again by retries.
+	Path string
+	// Size is the length of the content if known, for progress. It is read
+	// from the file if Path is set.
+	Size int64
+
+	// Sum is the hex digest of the content sent, set once the body is sent
+	// if MultipartConf.Checksum is set.
+	Sum string
+}
+
+// MultipartConf describes a multipart/form-data body streamed by SetMultipart.
+type MultipartConf struct {
+	Fields map[string]string
+	Files  []MultipartFile
+
+	// Progress, if set, is called after each chunk of file content is sent.
+	// total is -1 if the size of a file is unknown.
+	Progress func(sent, total int64)
+	// Checksum, if set, computes MultipartFile.Sum, e.g. sha256.New.
+	Checksum func() hash.Hash
+
+	mu   sync.Mutex
+	used bool
+}
+
+var errMultipartReused = errors.New("httpc: multipart body read from an io.Reader cannot be sent twice")
+
+// SetMultipart streams conf as the request body, without buffering files in
+// memory. The body is produced while it is sent, so retries can only resend
+// files given by Path.
+func SetMultipart(conf *MultipartConf) RequestFunc {
+	return func(r *resty.Request) {
+		boundary := multipart.NewWriter(io.Discard).Boundary()
+		r.SetHeader("Content-Type", "multipart/form-data; boundary="+boundary)
+		setStreamedBody(r, &streamedBody{replayable: conf.replayable(), start: func() io.Reader {
+			if err := conf.use(); err != nil {
+				return pipe.WriterToReader(func(io.Writer) error { return err })
+			}
+			return pipe.WriterToReader(func(w io.Writer) error {
+				return conf.write(w, boundary)
+			})
+		}})
+	}
+}
+
+// use fails if the body was already sent and can't be produced again.
+func (conf *MultipartConf) use() error {
+	conf.mu.Lock()
+	defer conf.mu.Unlock()
+	if conf.used && !conf.replayable() {
+		return errMultipartReused
+	}
+	conf.used = true
+	return nil
+}
+
+// replayable tells whether the body can be produced more than once.
+func (conf *MultipartConf) replayable() bool {
+	for _, f := range conf.Files {
+		if f.Reader != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (conf *MultipartConf) write(w io.Writer, boundary string) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(conf.Fields))
+	for k := range conf.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := mw.WriteField(k, conf.Fields[k]); err != nil {
+			return err
+		}
+	}
+
+	p := &uploadProgress{fn: conf.Progress, total: conf.total()}
+	for i := range conf.Files {
+		if err := conf.writeFile(mw, &conf.Files[i], p); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (conf *MultipartConf) writeFile(mw *multipart.Writer, f *MultipartFile, p *uploadProgress) error {
+	src := f.Reader
+	if src == nil {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		src = file
+	}
+
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="`+escapeQuotes(f.Param)+`"; filename="`+escapeQuotes(f.FileName)+`"`)
+	h.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	dst := io.Writer(part)
+	var sum hash.Hash
+	if conf.Checksum != nil {
+		sum = conf.Checksum()
+		dst = io.MultiWriter(part, sum)
+	}
+	if _, err = io.Copy(&progressCounter{p: p, w: dst}, src); err != nil {
+		return err
+	}
+	if sum != nil {
+		f.Sum = hex.EncodeToString(sum.Sum(nil))
+	}
+	return nil
+}
+
+// total returns the size of all files, -1 if one is unknown.
+func (conf *MultipartConf) total() int64 {
+	var total int64
+	for _, f := range conf.Files {
+		size := f.Size
+		if size <= 0 && f.Reader == nil {
+			if st, err := os.Stat(f.Path); err == nil {
+				size = st.Size()
+			}
+		}
+		if size <= 0 {
+			return -1
+		}
+		total += size
+	}
+	return total
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+type uploadProgress struct {
+	fn    func(sent, total int64)
+	sent  int64
+	total int64
+}
+
+type progressCounter struct {
+	p *uploadProgress
+	w io.Writer
+}
+
+func (pc *progressCounter) Write(b []byte) (int, error) {
+	n, err := pc.w.Write(b)
+	pc.p.sent += int64(n)
+	if pc.p.fn != nil {
+		pc.p.fn(pc.p.sent, pc.p.total)
+	}
+	return n, err
+}
+
+// streamedBody is a request body produced while it is sent. resty reads
+// io.Reader bodies in memory to keep a copy for redirects, so the body is
+// set on the http.Request by streamTransport instead.
+type streamedBody struct {
+	start      func() io.Reader
+	replayable bool
+}
+
+var streamedBodyKey = contextutil.NewIface()
+
+func setStreamedBody(r *resty.Request, b *streamedBody) {
+	r.SetBody(nil)
+	r.SetContext(streamedBodyKey.WithInterface(r.Context(), b))
+}
+
+func getStreamedBody(ctx context.Context) (*streamedBody, bool) {
+	b, ok := streamedBodyKey.Interface(ctx).(*streamedBody)
+	return b, ok
+}
+
+// streamTransport sends the streamed body of a request, see setStreamedBody.
+// Its GetBody produces the body again for redirects, if it is replayable.
+type streamTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *streamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, ok := getStreamedBody(req.Context())
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Body = &lazyReader{start: b.start}
+	req.ContentLength = -1
+	req.GetBody = nil
+	if b.replayable {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return &lazyReader{start: b.start}, nil
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// lazyReader starts producing the body on the first Read, so that requests
+// which are never sent don't leave a writer blocked on the pipe. Closing it
+// stops the writer.
+type lazyReader struct {
+	start func() io.Reader
+
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lazyReader) reader() io.Reader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.r == nil {
+		l.r = l.start()
+	}
+	return l.r
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	return l.reader().Read(p)
+}
+
+func (l *lazyReader) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package httpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMultipart(t *testing.T) {
+	type part struct{ name, fileName, contentType, content string }
+	var parts []part
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if !assert.NoError(t, err) {
+			return
+		}
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			b, _ := io.ReadAll(p)
+			parts = append(parts, part{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), string(b)})
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("from path"), 0o600))
+
+	var sent, total int64
+	conf := &MultipartConf{
+		Fields: map[string]string{"b": "2", "a": "1"},
+		Files: []MultipartFile{
+			{Param: "doc", FileName: "a.txt", ContentType: "text/plain", Path: path},
+			{Param: "raw", FileName: `say "hi".bin`, Reader: strings.NewReader("from reader"), Size: 11},
+		},
+		Progress: func(s, t int64) { sent, total = s, t },
+		Checksum: sha256.New,
+	}
+	_, err := New().PostCtx(context.Background(), server.URL, SetMultipart(conf))
+	assert.NoError(t, err)
+	assert.Equal(t, []part{
+		{"a", "", "", "1"},
+		{"b", "", "", "2"},
+		{"doc", "a.txt", "text/plain", "from path"},
+		{"raw", `say "hi".bin`, "application/octet-stream", "from reader"},
+	}, parts)
+	assert.Equal(t, int64(20), sent)
+	assert.Equal(t, int64(20), total)
+	sum := sha256.Sum256([]byte("from reader"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), conf.Files[1].Sum)
+
+	// The reader is consumed, the body can't be sent again.
+	_, err = New().PostCtx(context.Background(), server.URL, SetMultipart(conf))
+	assert.ErrorIs(t, err, errMultipartReused)
+}
+
+func TestSetMultipart_Streams(t *testing.T) {
+	const size = 32 << 20
+	var sent, sentAtStart atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentAtStart.Store(sent.Load())
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	conf := &MultipartConf{
+		Files:    []MultipartFile{{Param: "file", FileName: "zeros", Reader: io.LimitReader(zeros{}, size), Size: size}},
+		Progress: func(s, _ int64) { sent.Store(s) },
+	}
+	_, err := New().PostCtx(context.Background(), server.URL, SetMultipart(conf))
+	assert.NoError(t, err)
+	// The body is still being sent when the handler starts, it isn't read in
+	// memory first.
+	assert.Less(t, sentAtStart.Load(), int64(size))
+	assert.Equal(t, int64(size), sent.Load())
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestSetMultipart_Retry(t *testing.T) {
+	var contents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); !assert.NoError(t, err) {
+			return
+		}
+		f, _, err := r.FormFile("doc")
+		if !assert.NoError(t, err) {
+			return
+		}
+		b, _ := io.ReadAll(f)
+		contents = append(contents, string(b))
+		if len(contents) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("from path"), 0o600))
+	conf := &MultipartConf{Files: []MultipartFile{{Param: "doc", FileName: "a.txt", Path: path}}}
+	_, err := New(SetRetryWaitTime(time.Millisecond)).PutCtx(context.Background(), server.URL, SetMultipart(conf))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"from path", "from path"}, contents)
+}
@@ -0,0 +1,197 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/trace"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+	"github.com/leclerc04/go-tool/errorx"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultEarlyRenew is how long before expiry a token is renewed.
+const DefaultEarlyRenew = time.Minute
+
+// Token is an OAuth2 bearer token.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time // Expiry is zero if the token never expires.
+}
+
+// TokenSource provides tokens for outbound requests.
+type TokenSource interface {
+	// Token returns a valid token, refreshing it if needed.
+	Token(ctx context.Context) (*Token, error)
+	// Invalidate drops tok after the server rejected it, so that the next
+	// Token call fetches a new one.
+	Invalidate(tok *Token)
+}
+
+// OAuth2Conf configures an OAuth2TokenSource.
+type OAuth2Conf struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken switches to the refresh_token grant, otherwise the
+	// client_credentials grant is used.
+	RefreshToken string
+	// AuthInHeader sends the client credentials with basic auth instead of
+	// the form body.
+	AuthInHeader bool
+	// EarlyRenew renews the token this long before it expires, DefaultEarlyRenew if zero.
+	EarlyRenew time.Duration
+	// Client sends the token requests, a new client if nil.
+	Client *Client
+}
+
+// OAuth2TokenSource fetches and caches tokens from an OAuth2 token endpoint.
+// Concurrent callers share a single refresh.
+type OAuth2TokenSource struct {
+	conf  OAuth2Conf
+	group singleflight.Group
+
+	mu           sync.Mutex
+	token        *Token
+	refreshToken string
+}
+
+// NewOAuth2TokenSource creates an OAuth2TokenSource.
+func NewOAuth2TokenSource(conf OAuth2Conf) *OAuth2TokenSource {
+	if conf.EarlyRenew == 0 {
+		conf.EarlyRenew = DefaultEarlyRenew
+	}
+	if conf.Client == nil {
+		conf.Client = New()
+	}
+	return &OAuth2TokenSource{conf: conf, refreshToken: conf.RefreshToken}
+}
+
+// Token implements TokenSource.
+func (s *OAuth2TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	tok := s.token
+	s.mu.Unlock()
+	if tok != nil && (tok.Expiry.IsZero() || timeutil.Now().Add(s.conf.EarlyRenew).Before(tok.Expiry)) {
+		return tok, nil
+	}
+
+	ch := s.group.DoChan("token", func() (interface{}, error) {
+		// The refresh is shared, so it must not be cancelled by the first caller.
+		return s.fetch(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return nil, errs.Wrap(ctx.Err())
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.(*Token), nil
+	}
+}
+
+// Invalidate implements TokenSource.
+func (s *OAuth2TokenSource) Invalidate(tok *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil && tok != nil && s.token.AccessToken == tok.AccessToken {
+		s.token = nil
+	}
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (s *OAuth2TokenSource) fetch(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	refreshToken := s.refreshToken
+	s.mu.Unlock()
+
+	form := map[string]string{"grant_type": "client_credentials"}
+	if refreshToken != "" {
+		form = map[string]string{"grant_type": "refresh_token", "refresh_token": refreshToken}
+	}
+	if len(s.conf.Scopes) > 0 {
+		form["scope"] = strings.Join(s.conf.Scopes, " ")
+	}
+	var result tokenResponse
+	rfs := []RequestFunc{SetResult(&result), SetHeader("Accept", "application/json")}
+	if s.conf.AuthInHeader {
+		rfs = append(rfs, func(r *resty.Request) {
+			r.SetBasicAuth(s.conf.ClientID, s.conf.ClientSecret)
+		})
+	} else {
+		form["client_id"] = s.conf.ClientID
+		form["client_secret"] = s.conf.ClientSecret
+	}
+	rfs = append(rfs, SetFormData(form), func(r *resty.Request) {
+		r.SetError(&result)
+	})
+
+	trace.Printc(ctx, "oauth2: fetching token", "grant_type", form["grant_type"])
+	res, err := s.conf.Client.PostCtx(ctx, s.conf.TokenURL, rfs...)
+	if err != nil {
+		if res != nil && result.Error != "" {
+			return nil, errorx.New("http-OAuth2", res.StatusCode(), result.Error).
+				WithMetadata(errorx.Metadata{"description": result.ErrorDescription}).
+				WithError(err)
+		}
+		return nil, err
+	}
+	if result.AccessToken == "" {
+		return nil, errorx.New("http-OAuth2", http.StatusBadGateway, "token endpoint returned no access_token")
+	}
+
+	tok := &Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    result.TokenType,
+		RefreshToken: result.RefreshToken,
+	}
+	if tok.TokenType == "" {
+		tok.TokenType = "Bearer"
+	}
+	if result.ExpiresIn > 0 {
+		tok.Expiry = timeutil.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = tok
+	if tok.RefreshToken != "" {
+		s.refreshToken = tok.RefreshToken
+	}
+	return tok, nil
+}
+
+// requestWithToken authorizes the request with c.TokenSource, and retries it
+// once with a new token if the server answers 401.
+func (c *Client) requestWithToken(ctx context.Context, method, url string, rfs []RequestFunc) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		tok, err := c.TokenSource.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		authRfs := append(rfs[:len(rfs):len(rfs)], SetHeader("Authorization", tok.TokenType+" "+tok.AccessToken))
+		res, err := c.request(ctx, method, url, authRfs)
+		if attempt > 0 || res == nil || res.StatusCode() != http.StatusUnauthorized {
+			return res, err
+		}
+		trace.Printf(ctx, "oauth2: token rejected, refreshing")
+		c.TokenSource.Invalidate(tok)
+	}
+}
@@ -0,0 +1,91 @@
+package httpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2TokenSource(t *testing.T) {
+	timeutil.SetAFakeTime()
+	defer timeutil.UnSetFakeTime()
+
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"t%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	ts := NewOAuth2TokenSource(OAuth2Conf{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := ts.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "t1", tok.AccessToken)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), issued.Load())
+
+	// Renewed ahead of expiry.
+	timeutil.AdvanceFakeTime(3600*time.Second - DefaultEarlyRenew)
+	tok, err := ts.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "t2", tok.AccessToken)
+
+	_, err = NewOAuth2TokenSource(OAuth2Conf{TokenURL: tokenServer.URL, ClientID: "id"}).Token(context.Background())
+	assert.Error(t, err)
+}
+
+func TestClientWithTokenSource_RetryOn401(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"t%d","expires_in":3600}`, issued.Add(1))
+	}))
+	defer tokenServer.Close()
+
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Authorization") != "Bearer t2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	ts := NewOAuth2TokenSource(OAuth2Conf{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"})
+	cl := New(SetTokenSource(ts))
+	_, err := cl.Get(api.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// Only one retry: a token that keeps being rejected is returned as error.
+	calls.Store(0)
+	issued.Store(10)
+	ts.Invalidate(&Token{AccessToken: "t2"})
+	_, err = cl.Get(api.URL)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
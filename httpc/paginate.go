@@ -0,0 +1,339 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/base/concurrent"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+)
+
+// DefaultPageSize is the page size of PageConf if not set.
+const DefaultPageSize = 100
+
+// PageStyle is how a list endpoint is paginated.
+type PageStyle int
+
+const (
+	// PageNumber sends page and size, e.g. ?page=2&size=100.
+	PageNumber PageStyle = iota
+	// PageOffset sends offset and limit, e.g. ?offset=200&limit=100.
+	PageOffset
+	// PageCursor sends the cursor returned by the previous page, e.g. ?cursor=abc&limit=100.
+	PageCursor
+	// PageLink follows the rel="next" URL of the Link header, as GitHub does.
+	PageLink
+)
+
+// Page is a decoded page of a list endpoint.
+type Page[T any] struct {
+	Items []T
+	Next  string // Next is the cursor of the next page for PageCursor, empty on the last page.
+	Total int    // Total is the total number of items if the endpoint returns it, else zero.
+}
+
+// PageConf configures Paginate.
+type PageConf[T any] struct {
+	Style    PageStyle
+	Method   string // Method is GET if empty.
+	URL      string
+	PageSize int // PageSize is DefaultPageSize if zero.
+
+	// PageParam and SizeParam name the query parameters, "page" and "size"
+	// for PageNumber, "offset" and "limit" for PageOffset, "cursor" and
+	// "limit" for PageCursor by default.
+	PageParam string
+	SizeParam string
+	// ZeroBased numbers pages from 0 for PageNumber, they start at 1 by default.
+	ZeroBased bool
+
+	// Decode extracts a page from a response, DecodePage[T]("", "", "") if nil.
+	Decode func(res *resty.Response) (Page[T], error)
+	// MaxPages stops after that many pages, zero for no limit.
+	MaxPages int
+	// Concurrency fetches that many pages at once for PageNumber and
+	// PageOffset. Pages after the last one may be requested, their
+	// responses and errors are ignored.
+	Concurrency int
+}
+
+// Pager iterates over the items of a paginated endpoint, fetching pages lazily:
+//
+//	p := httpc.Paginate(ctx, c, httpc.PageConf[User]{URL: "/users", Decode: httpc.DecodePage[User]("data.list", "", "data.total")})
+//	for p.Next() {
+//		u := p.Item()
+//	}
+//	if err := p.Err(); err != nil {
+//	}
+type Pager[T any] struct {
+	ctx  context.Context
+	c    *Client
+	conf PageConf[T]
+	rfs  []RequestFunc
+
+	items   []T
+	item    T
+	pages   int // pages is the number of pages fetched.
+	fetched int // fetched is the number of items fetched.
+	next    string
+	done    bool
+	err     error
+}
+
+// Paginate creates a Pager, rfs are applied to every page request. Requests
+// go through c, so they are subject to its limiter and retry policy.
+func Paginate[T any](ctx context.Context, c *Client, conf PageConf[T], rfs ...RequestFunc) *Pager[T] {
+	if conf.Method == "" {
+		conf.Method = http.MethodGet
+	}
+	if conf.PageSize <= 0 {
+		conf.PageSize = DefaultPageSize
+	}
+	if conf.Decode == nil {
+		conf.Decode = DecodePage[T]("", "", "")
+	}
+	if conf.Concurrency <= 0 || conf.Style == PageCursor || conf.Style == PageLink {
+		conf.Concurrency = 1
+	}
+	pageParam, sizeParam := "page", "size"
+	switch conf.Style {
+	case PageOffset:
+		pageParam, sizeParam = "offset", "limit"
+	case PageCursor:
+		pageParam, sizeParam = "cursor", "limit"
+	}
+	if conf.PageParam == "" {
+		conf.PageParam = pageParam
+	}
+	if conf.SizeParam == "" {
+		conf.SizeParam = sizeParam
+	}
+	return &Pager[T]{ctx: ctx, c: c, conf: conf, rfs: rfs, next: conf.URL}
+}
+
+// Next advances to the next item, fetching the next page if needed. It
+// returns false at the end or on error, see Err.
+func (p *Pager[T]) Next() bool {
+	for len(p.items) == 0 {
+		if p.done || p.err != nil {
+			return false
+		}
+		if err := p.ctx.Err(); err != nil {
+			p.err = errs.Wrap(err)
+			return false
+		}
+		p.err = p.fetch()
+	}
+	p.item, p.items = p.items[0], p.items[1:]
+	return true
+}
+
+// Item returns the current item.
+func (p *Pager[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+// All collects the remaining items.
+func (p *Pager[T]) All() ([]T, error) {
+	var all []T
+	for p.Next() {
+		all = append(all, p.Item())
+	}
+	return all, p.Err()
+}
+
+func (p *Pager[T]) fetch() error {
+	n := p.conf.Concurrency
+	if p.conf.MaxPages > 0 && p.pages+n > p.conf.MaxPages {
+		n = p.conf.MaxPages - p.pages
+	}
+	pages := make([]Page[T], n)
+	errors := make([]error, n)
+	if n == 1 {
+		pages[0], errors[0] = p.fetchPage(0)
+	} else {
+		pool, wait := concurrent.NewPool(n, n)
+		for i := 0; i < n; i++ {
+			i := i
+			pool.Run(func() error {
+				pages[i], errors[i] = p.fetchPage(i)
+				return nil
+			})
+		}
+		_ = wait()
+	}
+
+	// Walk the pages in order up to the last one, the pages after it may
+	// fail, e.g. with a 404, and are ignored. The error of the first failed
+	// page is returned rather than a MultiError, so that callers can inspect it.
+	for i, page := range pages {
+		if errors[i] != nil {
+			return errors[i]
+		}
+		p.pages++
+		p.fetched += len(page.Items)
+		p.items = append(p.items, page.Items...)
+		if p.last(page) {
+			p.done = true
+			break
+		}
+	}
+	if p.conf.MaxPages > 0 && p.pages >= p.conf.MaxPages {
+		p.done = true
+	}
+	return nil
+}
+
+// last tells whether page is the last one.
+func (p *Pager[T]) last(page Page[T]) bool {
+	switch p.conf.Style {
+	case PageCursor:
+		p.next = page.Next
+		return page.Next == ""
+	case PageLink:
+		return p.next == ""
+	}
+	if page.Total > 0 && p.fetched >= page.Total {
+		return true
+	}
+	return len(page.Items) < p.conf.PageSize
+}
+
+// fetchPage fetches the i-th page after the ones already fetched.
+func (p *Pager[T]) fetchPage(i int) (Page[T], error) {
+	rfs := append(p.rfs[:len(p.rfs):len(p.rfs)], SetQueryParam(p.conf.SizeParam, strconv.Itoa(p.conf.PageSize)))
+	target := p.conf.URL
+	switch p.conf.Style {
+	case PageNumber:
+		page := p.pages + i
+		if !p.conf.ZeroBased {
+			page++
+		}
+		rfs = append(rfs, SetQueryParam(p.conf.PageParam, strconv.Itoa(page)))
+	case PageOffset:
+		rfs = append(rfs, SetQueryParam(p.conf.PageParam, strconv.Itoa((p.pages+i)*p.conf.PageSize)))
+	case PageCursor:
+		if p.pages > 0 {
+			rfs = append(rfs, SetQueryParam(p.conf.PageParam, p.next))
+		}
+	case PageLink:
+		if p.pages > 0 {
+			// The next URL carries its own query, including the page size.
+			rfs = p.rfs
+		}
+		target = p.next
+	}
+
+	res, err := p.c.RequestCtx(p.ctx, p.conf.Method, target, rfs...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	if p.conf.Style == PageLink {
+		p.next = nextLink(res)
+	}
+	return p.conf.Decode(res)
+}
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>\s*((?:;\s*[^;,]*)*)`)
+
+// nextLink returns the rel="next" URL of the Link header of res, resolved
+// against the request URL.
+func nextLink(res *resty.Response) string {
+	for _, header := range res.Header().Values("Link") {
+		for _, m := range linkPattern.FindAllStringSubmatch(header, -1) {
+			if !isNextRel(m[2]) {
+				continue
+			}
+			next, err := url.Parse(m[1])
+			if err != nil {
+				return ""
+			}
+			if raw := res.RawResponse; raw != nil && raw.Request != nil {
+				next = raw.Request.URL.ResolveReference(next)
+			}
+			return next.String()
+		}
+	}
+	return ""
+}
+
+func isNextRel(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(k, "rel") {
+			for _, rel := range strings.Fields(strings.Trim(v, `"`)) {
+				if strings.EqualFold(rel, "next") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// DecodePage decodes JSON pages. The paths are dot separated fields of the
+// body, e.g. "data.list"; an empty itemsPath means the body is the list, and
+// empty nextPath or totalPath mean the body has no such field.
+func DecodePage[T any](itemsPath, nextPath, totalPath string) func(res *resty.Response) (Page[T], error) {
+	return func(res *resty.Response) (Page[T], error) {
+		var page Page[T]
+		var body interface{}
+		// Numbers are kept as json.Number, float64 would round large ids.
+		d := json.NewDecoder(bytes.NewReader(res.Body()))
+		d.UseNumber()
+		if err := d.Decode(&body); err != nil {
+			return page, decodeError(res, err)
+		}
+		items, err := json.Marshal(jsonPath(body, itemsPath))
+		if err != nil {
+			return page, decodeError(res, err)
+		}
+		if err = json.Unmarshal(items, &page.Items); err != nil {
+			return page, decodeError(res, err)
+		}
+		if nextPath != "" {
+			switch next := jsonPath(body, nextPath).(type) {
+			case string:
+				page.Next = next
+			case json.Number:
+				page.Next = next.String()
+			}
+		}
+		if totalPath != "" {
+			if total, ok := jsonPath(body, totalPath).(json.Number); ok {
+				n, err := total.Int64()
+				if err != nil {
+					return page, decodeError(res, err)
+				}
+				page.Total = int(n)
+			}
+		}
+		return page, nil
+	}
+}
+
+func jsonPath(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, field := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[field]
+	}
+	return v
+}
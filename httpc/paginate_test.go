@@ -0,0 +1,146 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pageTestItems = 25
+
+func pageTestServer(t *testing.T, requests *int32) *httptest.Server {
+	items := make([]int, pageTestItems)
+	for i := range items {
+		items[i] = i
+	}
+	slice := func(offset, limit int) []int {
+		if offset > len(items) {
+			offset = len(items)
+		}
+		end := offset + limit
+		if end > len(items) {
+			end = len(items)
+		}
+		return items[offset:end]
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		q := r.URL.Query()
+		atoi := func(k string) int {
+			n, _ := strconv.Atoi(q.Get(k))
+			return n
+		}
+		var body interface{}
+		switch strings.TrimPrefix(r.URL.Path, "/api") {
+		case "/page":
+			body = map[string]interface{}{"data": map[string]interface{}{
+				"list": slice((atoi("page")-1)*atoi("size"), atoi("size")), "total": len(items)}}
+		case "/strict":
+			// Pages after the last one are rejected.
+			if (atoi("page")-1)*atoi("size") >= len(items) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = slice((atoi("page")-1)*atoi("size"), atoi("size"))
+		case "/page0":
+			body = slice(atoi("page")*atoi("size"), atoi("size"))
+		case "/big":
+			// The cursor doesn't fit in a float64.
+			if q.Get("cursor") == "" {
+				_, _ = w.Write([]byte(`{"items": [0, 1], "next": 9007199254740993}`))
+			} else if q.Get("cursor") == "9007199254740993" {
+				_, _ = w.Write([]byte(`{"items": [2], "next": null}`))
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		case "/offset":
+			body = slice(atoi("offset"), atoi("limit"))
+		case "/cursor":
+			page := slice(atoi("cursor"), atoi("limit"))
+			next := ""
+			if atoi("cursor")+len(page) < len(items) {
+				next = strconv.Itoa(atoi("cursor") + len(page))
+			}
+			body = map[string]interface{}{"items": page, "next_cursor": next}
+		case "/link":
+			page := atoi("page")
+			if (page+1)*10 < len(items) {
+				w.Header().Add("Link", fmt.Sprintf(`</link?page=%d>; rel="next", </link?page=0>; rel="first"`, page+1))
+			}
+			body = slice(page*10, 10)
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func TestPaginate(t *testing.T) {
+	var requests int32
+	server := pageTestServer(t, &requests)
+	defer server.Close()
+	c := New(SetHostURL(server.URL))
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		conf     PageConf[int]
+		requests int32
+	}{
+		{"page", PageConf[int]{URL: "/page", PageSize: 10, Decode: DecodePage[int]("data.list", "", "data.total")}, 3},
+		{"offset", PageConf[int]{Style: PageOffset, URL: "/offset", PageSize: 5}, 6},
+		{"offset concurrent", PageConf[int]{Style: PageOffset, URL: "/offset", PageSize: 5, Concurrency: 4}, 8},
+		{"page concurrent past the end", PageConf[int]{URL: "/strict", PageSize: 10, Concurrency: 4}, 4},
+		{"cursor", PageConf[int]{Style: PageCursor, URL: "/cursor", PageSize: 10, Decode: DecodePage[int]("items", "next_cursor", "")}, 3},
+		{"page zero based", PageConf[int]{URL: "/page0", PageSize: 10, ZeroBased: true}, 3},
+		{"link", PageConf[int]{Style: PageLink, URL: "/link?page=0"}, 3},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&requests, 0)
+		items, err := Paginate(ctx, c, test.conf).All()
+		require.NoError(t, err, test.name)
+		assert.Len(t, items, pageTestItems, test.name)
+		for i, v := range items {
+			assert.Equal(t, i, v, test.name)
+		}
+		assert.Equal(t, test.requests, atomic.LoadInt32(&requests), test.name)
+	}
+
+	items, err := Paginate(ctx, c, PageConf[int]{Style: PageOffset, URL: "/offset", PageSize: 5, MaxPages: 2}).All()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, items)
+
+	items, err = Paginate(ctx, c, PageConf[int]{Style: PageCursor, URL: "/big", Decode: DecodePage[int]("items", "next", "")}).All()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, items)
+
+	// Absolute next links are not prefixed with the BaseURI.
+	items, err = Paginate(ctx, New(SetHostURL(server.URL), SetBaseURI("/api")), PageConf[int]{Style: PageLink, URL: "/link?page=0"}).All()
+	assert.NoError(t, err)
+	assert.Len(t, items, pageTestItems)
+}
+
+func TestPaginate_Cancelled(t *testing.T) {
+	var requests int32
+	server := pageTestServer(t, &requests)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Paginate(ctx, New(SetHostURL(server.URL)), PageConf[int]{Style: PageOffset, URL: "/offset", PageSize: 5})
+	for i := 0; i < 5; i++ {
+		require.True(t, p.Next())
+	}
+	cancel()
+	assert.False(t, p.Next())
+	assert.True(t, errs.IsCancelled(p.Err()), "unexpected error: %v", p.Err())
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
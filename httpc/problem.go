@@ -0,0 +1,167 @@
+package httpc
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/zeromicro/go-zero/core/logc"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, see
+// https://www.rfc-editor.org/rfc/rfc7807.
+//
+// Problems written by RespError carry the errorx code and business type in
+// the "code" and "biz_type" extension members, followed by the metadata of
+// the errors shown to clients.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions are the other members of the object.
+	Extensions map[string]any `json:"-"`
+}
+
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		if !problemMembers[k] {
+			m[k] = v
+		}
+	}
+	type problem Problem
+	b, err := json.Marshal(problem(p))
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	type problem Problem
+	if err := json.Unmarshal(b, (*problem)(p)); err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for k := range problemMembers {
+		delete(m, k)
+	}
+	p.Extensions = m
+	return nil
+}
+
+// ToError converts the problem back into an *errorx.Error, using the "code"
+// and "biz_type" members if present. The other extension members, the type
+// and the instance are kept in the metadata.
+func (p *Problem) ToError() *errorx.Error {
+	code := p.Status
+	if c, ok := p.Extensions["code"].(float64); ok && c > 0 {
+		code = int(c)
+	}
+	bizType, _ := p.Extensions["biz_type"].(string)
+	if bizType == "" {
+		bizType = p.Title
+	}
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+
+	metadata := errorx.Metadata{"status": p.Status}
+	for k, v := range p.Extensions {
+		if k != "code" && k != "biz_type" {
+			metadata[k] = v
+		}
+	}
+	if p.Type != "" && p.Type != "about:blank" {
+		metadata["type"] = p.Type
+	}
+	if p.Instance != "" {
+		metadata["instance"] = p.Instance
+	}
+	return errorx.New(bizType, code, msg).WithMetadata(metadata)
+}
+
+// NewErrorFromProblem decodes an application/problem+json response into an
+// *errorx.Error, it returns false for other responses.
+func NewErrorFromProblem(res *resty.Response) (*errorx.Error, bool) {
+	if !isProblem(res) {
+		return nil, false
+	}
+	var p Problem
+	if err := json.Unmarshal(res.Body(), &p); err != nil {
+		return nil, false
+	}
+	if p.Status == 0 {
+		p.Status = res.StatusCode()
+	}
+	e := p.ToError()
+	if res.Request != nil {
+		e.Metadata["method"] = res.Request.Method
+		e.Metadata["url"] = res.Request.URL
+	}
+	return e, true
+}
+
+func isProblem(res *resty.Response) bool {
+	if res == nil || res.RawResponse == nil {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header().Get("Content-Type"))
+	return mediaType == ProblemContentType
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, e respError) {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.status),
+		Status:   e.status,
+		Detail:   e.res.Msg,
+		Instance: r.URL.RequestURI(),
+		Extensions: map[string]any{
+			"code": e.res.Code,
+		},
+	}
+	if e.status == StatusClientClosedRequest {
+		p.Title = "Client Closed Request"
+	}
+	// Business types of the errorx helpers are the status text, the type
+	// adds nothing to the status then.
+	if e.bizType != "" && e.bizType != p.Title {
+		p.Type = respConf.ProblemTypeBase + url.PathEscape(e.bizType)
+		p.Extensions["biz_type"] = e.bizType
+	}
+	if metadata, ok := e.metadata.(errorx.Metadata); ok && e.shown {
+		for k, v := range metadata {
+			if _, ok := p.Extensions[k]; !ok {
+				p.Extensions[k] = v
+			}
+		}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		logc.Errorw(r.Context(), "marshal problem", logc.Field("err", err))
+		p.Extensions = map[string]any{"code": e.res.Code}
+		b, _ = json.Marshal(p)
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(e.status)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,82 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leclerc04/go-tool/errorx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespError_Problem(t *testing.T) {
+	defer SetRespConf(respConf)
+	SetRespConf(RespConf{Mode: RespModeProblem, ProblemTypeBase: "https://errors.example.com/"})
+
+	w := httptest.NewRecorder()
+	err := errorx.New("user-Login", 404001, "no such user").
+		WithMetadata(errorx.Metadata{"user": "bob", "status": "ignored"}).Show()
+	RespError(w, httptest.NewRequest(http.MethodGet, "/users/bob?x=1", nil), err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "https://errors.example.com/user-Login",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "no such user",
+		"instance": "/users/bob?x=1",
+		"code": 404001,
+		"biz_type": "user-Login",
+		"user": "bob"
+	}`, w.Body.String())
+
+	// Metadata of errors not shown stays private.
+	w = httptest.NewRecorder()
+	RespError(w, httptest.NewRequest(http.MethodGet, "/", nil),
+		errorx.Internal(errors.New("db"), "query").WithMetadata(errorx.Metadata{"sql": "select"}))
+	var p Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, Problem{
+		Type:       "about:blank",
+		Title:      "Internal Server Error",
+		Status:     http.StatusInternalServerError,
+		Detail:     errorx.CodeInternalErr.Msg(),
+		Instance:   "/",
+		Extensions: map[string]any{"code": float64(500)},
+	}, p)
+}
+
+func TestNewErrorFromProblem(t *testing.T) {
+	defer SetRespConf(respConf)
+	SetRespConf(RespConf{Mode: RespModeProblem})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name string `form:"name" validate:"required"`
+		}
+		if err := Bind(r, &req); err != nil {
+			RespError(w, r, err)
+			return
+		}
+		RespSuccess(r.Context(), w, req.Name)
+	}))
+	defer server.Close()
+
+	_, err := CallJSON[string](context.Background(), New(), http.MethodGet, server.URL+"/hello")
+	var e *errorx.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, int(errorx.CodeInvalidParams), e.Code)
+	assert.Equal(t, "name不能为空", e.Msg)
+	assert.Equal(t, "Bad Request", e.BizType)
+	assert.Equal(t, http.StatusBadRequest, e.Metadata["status"])
+	assert.Equal(t, "/hello", e.Metadata["instance"])
+	assert.Equal(t, []any{map[string]any{"field": "name", "code": float64(errorx.CodeFieldRequired), "msg": "name不能为空"}},
+		e.Metadata["fields"])
+
+	name, err := CallJSON[string](context.Background(), New(), http.MethodGet, server.URL+"/hello?name=bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", name)
+}
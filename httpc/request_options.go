@@ -6,6 +6,7 @@ import (
 	"net/url"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/util/contextutil"
 )
 
 type RequestFunc func(request *resty.Request)
@@ -16,27 +17,57 @@ func SetResult(res interface{}) RequestFunc {
 	}
 }
 
+// SetBody sets the request body. An io.Reader body is read once and replayed
+// to every attempt, so that retries send it again.
 func SetBody(body interface{}) RequestFunc {
+	if rd, ok := body.(io.Reader); ok && !isReplayable(rd) {
+		rb := &replayBody{src: rd}
+		return func(r *resty.Request) {
+			r.SetBody(rb.reader())
+		}
+	}
 	return func(r *resty.Request) {
 		r.SetBody(body)
 	}
 }
 
+var doNotParseKey = contextutil.NewBool()
+
+// SetDoNotParseResponse leaves the response body for the caller to read and
+// close, see resty.Request.SetDoNotParseResponse. Such requests are not hedged.
+func SetDoNotParseResponse() RequestFunc {
+	return func(r *resty.Request) {
+		r.SetDoNotParseResponse(true)
+		r.SetContext(doNotParseKey.WithValue(r.Context(), true))
+	}
+}
+
 func SetHeader(header, value string) RequestFunc {
 	return func(r *resty.Request) {
 		r.SetHeader(header, value)
 	}
 }
 
+// SetIdempotencyKey allows a POST or PATCH to be retried, the server is
+// expected to deduplicate requests carrying the same key.
+func SetIdempotencyKey(key string) RequestFunc {
+	return func(r *resty.Request) {
+		r.SetHeader(IdempotencyKeyHeader, key)
+	}
+}
+
 func SetHeaders(headers map[string]string) RequestFunc {
 	return func(r *resty.Request) {
 		r.SetHeaders(headers)
 	}
 }
 
+// SetFileReader adds a multipart file read from reader. Like with SetBody,
+// reader is read once and replayed to every attempt.
 func SetFileReader(param, fileName string, reader io.Reader) RequestFunc {
+	rb := &replayBody{src: reader}
 	return func(r *resty.Request) {
-		r.SetFileReader(param, fileName, reader)
+		r.SetFileReader(param, fileName, rb.reader())
 	}
 }
 
@@ -0,0 +1,26 @@
+package httpc
+
+import (
+	"context"
+
+	"github.com/leclerc04/go-tool/agl/util/contextutil"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// RequestIDHeader carries the ID of a request across services.
+const RequestIDHeader = "X-Request-Id"
+
+var requestIDKey = contextutil.NewIface()
+
+// WithRequestID returns a context carrying the request ID, which is added to
+// its logs and sent by the requests of Client.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = logx.ContextWithFields(ctx, logx.Field("request_id", id))
+	return requestIDKey.WithInterface(ctx, id)
+}
+
+// RequestID returns the request ID set by WithRequestID.
+func RequestID(ctx context.Context) string {
+	id, _ := requestIDKey.Interface(ctx).(string)
+	return id
+}
@@ -5,57 +5,205 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/leclerc04/go-tool/agl/base/sentry"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/i18n"
 	"github.com/leclerc04/go-tool/errorx"
 	"github.com/zeromicro/go-zero/core/logc"
 	"github.com/zeromicro/go-zero/rest/httpx"
+	"golang.org/x/text/language"
 )
 
+// StatusClientClosedRequest is the non standard status used by nginx when the
+// client goes away before the response is written.
+const StatusClientClosedRequest = 499
+
 type Response struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 	Data any    `json:"data"`
 }
 
+// RespMode decides how RespError writes errors.
+type RespMode int
+
+const (
+	// RespModeOK answers HTTP 200 and puts the error code in Response.Code.
+	RespModeOK RespMode = iota
+	// RespModeStatus answers with the HTTP status of the error.
+	RespModeStatus
+	// RespModeProblem answers with the HTTP status of the error and an RFC
+	// 7807 application/problem+json body, see Problem.
+	RespModeProblem
+)
+
+// RespConf configures RespError, see SetRespConf.
+type RespConf struct {
+	Mode RespMode
+	// Report sends 5xx errors to sentry, tagged with the request.
+	Report bool
+	// Catalog holds the messages in the languages negotiated with
+	// i18n.RequestLanguages, errorx.Catalog if nil.
+	Catalog *i18n.Catalog
+	// ProblemTypeBase prefixes the business type of errors to build the type
+	// URI of problems, e.g. "https://errors.example.com/".
+	ProblemTypeBase string
+}
+
+var respConf RespConf
+
+// SetRespConf sets how RespError answers, it should be called at startup.
+func SetRespConf(conf RespConf) {
+	respConf = conf
+}
+
+// GetRespConf returns the conf set by SetRespConf.
+func GetRespConf() RespConf {
+	return respConf
+}
+
+// reportError is replaced in tests.
+var reportError = func(ctx context.Context, err error) {
+	sentry.ErrorDepth(ctx, 2, err)
+}
+
+// message returns the message of code in the first of langs having one, or
+// else the internal error message.
+func message(langs []language.Tag, code int, params map[string]any) string {
+	if msg, ok := respCatalog().Message(langs, code, params); ok {
+		return msg
+	}
+	return errorx.CodeInternalErr.Localize(langs, nil)
+}
+
+// RespSuccess answers resp, with the message in the languages set by
+// i18n.WithLanguages, e.g. by middleware.Languages.
 func RespSuccess(ctx context.Context, w http.ResponseWriter, resp interface{}) {
 	var body Response
 	body.Code = http.StatusOK
-	body.Msg = errorx.CodeSuccess.Msg()
+	body.Msg = message(i18n.Languages(ctx), int(errorx.CodeSuccess), nil)
 	body.Data = resp
 	httpx.OkJsonCtx(ctx, w, body)
 }
 
+// RespError answers err as configured by SetRespConf. Messages are in the
+// languages of i18n.RequestLanguages.
 func RespError(w http.ResponseWriter, r *http.Request, err error) {
-	var (
-		code      = http.StatusInternalServerError
-		res       = Response{Code: code, Msg: errorx.CodeInternalErr.Msg()}
-		metadata  any
-		bizType   string
-		errDetail string
+	ctx := r.Context()
+	e := respErrorOf(err, i18n.RequestLanguages(r))
+
+	logc.Errorw(ctx, e.detail,
+		logc.Field("err", err),
+		logc.Field("code", e.res.Code),
+		logc.Field("status", e.status),
+		logc.Field("type", e.bizType),
+		logc.Field("metadata", e.metadata),
+		logc.Field("method", r.Method),
+		logc.Field("path", r.URL.Path),
 	)
+	if respConf.Report && e.status >= http.StatusInternalServerError {
+		reportError(sentry.WithTags(ctx, &sentry.Tags{Request: r}), err)
+	}
+
+	switch respConf.Mode {
+	case RespModeStatus:
+		httpx.WriteJsonCtx(ctx, w, e.status, e.res)
+		return
+	case RespModeProblem:
+		writeProblem(w, r, e)
+		return
+	}
+	httpx.OkJsonCtx(ctx, w, e.res)
+}
+
+type respError struct {
+	status   int
+	res      Response
+	detail   string
+	bizType  string
+	metadata any
+	// shown tells whether the message and metadata are meant for the client.
+	shown bool
+}
+
+// respErrorOf maps err to its HTTP status and response body. *errorx.Error
+// keeps its code, an *errs.Error its Kind, cancellations are not reported as
+// server errors and everything else is a 500 with a generic message.
+func respErrorOf(err error, langs []language.Tag) respError {
+	e := respError{
+		status: http.StatusInternalServerError,
+		res:    Response{Code: http.StatusInternalServerError, Msg: message(langs, int(errorx.CodeInternalErr), nil)},
+	}
 
 	var customErr *errorx.Error
+	var kindErr *errs.Error
 	switch {
-	case errors.As(err, &customErr):
-		res.Code = customErr.Code
-		errDetail = customErr.Msg
+	case errors.As(err, &customErr) || errors.As(errs.Unwrap(err), &customErr):
+		e.res.Code = customErr.Code
+		e.detail = customErr.Msg
 		if customErr.IsShow {
-			res.Msg = customErr.Msg
+			e.shown = true
+			e.res.Msg = customErr.Msg
+			if customErr.IsLocalized {
+				e.res.Msg = localize(langs, customErr)
+			}
+		}
+		e.status = statusOfCode(customErr.Code)
+		e.bizType = customErr.BizType
+		e.metadata = customErr.Metadata
+	case errors.Is(errs.Unwrap(err), context.DeadlineExceeded):
+		e.status = http.StatusGatewayTimeout
+		e.res.Code = e.status
+		e.detail = err.Error()
+	case errs.IsCancelled(err) || errors.Is(errs.Unwrap(err), context.Canceled):
+		e.status = StatusClientClosedRequest
+		e.res.Code = e.status
+		e.detail = err.Error()
+	case errors.As(err, &kindErr):
+		e.status = kindErr.Kind.HTTPStatusCode()
+		e.res.Code = e.status
+		e.detail = kindErr.ErrorMessage()
+		e.bizType = kindErr.Kind.String()
+		if kindErr.SubKind != "" {
+			e.metadata = errorx.Metadata{"sub_kind": kindErr.SubKind}
+		}
+		// Only messages given to the error are shown, not the ones of the
+		// errors it wraps.
+		if kindErr.Kind != errs.Internal && kindErr.Message != "" {
+			e.shown = true
+			e.res.Msg = kindErr.Message
 		}
-		code = customErr.Code
-		bizType = customErr.BizType
-		metadata = customErr.Metadata
+	default:
+		e.detail = err.Error()
 	}
+	return e
+}
 
-	logc.Errorw(r.Context(), errDetail,
-		logc.Field("err", err),
-		logc.Field("code", code),
-		logc.Field("type", bizType),
-		logc.Field("metadata", metadata),
-		logc.Field("method", r.Method),
-		logc.Field("path", r.URL.Path),
-	)
+// localize returns the message of the code of e, or else e.Msg.
+func localize(langs []language.Tag, e *errorx.Error) string {
+	if msg, ok := respCatalog().Message(langs, e.Code, e.Metadata); ok {
+		return msg
+	}
+	return e.Msg
+}
 
-	httpx.OkJsonCtx(r.Context(), w, res)
+func respCatalog() *i18n.Catalog {
+	if respConf.Catalog != nil {
+		return respConf.Catalog
+	}
+	return errorx.Catalog
+}
+
+// statusOfCode returns the HTTP status of an errorx code, which is either an
+// error status or a business code prefixed by one, e.g. 404001. Other codes,
+// e.g. 200 or 100001, are 500 as an error can't answer a success.
+func statusOfCode(code int) int {
+	for _, c := range []int{code, code / 1000} {
+		if c >= http.StatusBadRequest && c <= 599 {
+			return c
+		}
+	}
+	return http.StatusInternalServerError
 }
 
 func JwtUnauthorizedResult(w http.ResponseWriter, r *http.Request, err error) {
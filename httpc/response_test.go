@@ -1,13 +1,105 @@
 package httpc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/i18n"
 	"github.com/leclerc04/go-tool/errorx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
 )
 
 func TestResponse(t *testing.T) {
 	err := errorx.New("test", int(errorx.CodeInternalErr), "test")
 
-	RespError(nil, nil, err)
+	w := httptest.NewRecorder()
+	RespError(w, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, int(errorx.CodeInternalErr), res.Code)
+	assert.Equal(t, errorx.CodeInternalErr.Msg(), res.Msg)
+}
+
+func TestRespError_Status(t *testing.T) {
+	defer SetRespConf(respConf)
+	SetRespConf(RespConf{Mode: RespModeStatus, Report: true})
+	defer func(f func(context.Context, error)) { reportError = f }(reportError)
+	var reported []error
+	reportError = func(ctx context.Context, err error) {
+		reported = append(reported, err)
+	}
+
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   int
+		msg    string
+	}{
+		{errorx.NotFound("no user").Show(), http.StatusNotFound, http.StatusNotFound, "no user"},
+		{errorx.New("user-Login", 404001, "用户不存在"), http.StatusNotFound, 404001, errorx.CodeInternalErr.Msg()},
+		{errs.Wrap(errorx.BadRequest("bad").Show()), http.StatusBadRequest, http.StatusBadRequest, "bad"},
+		{errs.Forbidden.New("not yours"), http.StatusForbidden, http.StatusForbidden, "not yours"},
+		{errs.TryAgain.Wrap(errors.New("db down")), http.StatusServiceUnavailable, http.StatusServiceUnavailable, errorx.CodeInternalErr.Msg()},
+		{errs.Internal.New("secret"), http.StatusInternalServerError, http.StatusInternalServerError, errorx.CodeInternalErr.Msg()},
+		{fmt.Errorf("query: %w", context.Canceled), StatusClientClosedRequest, StatusClientClosedRequest, errorx.CodeInternalErr.Msg()},
+		{errs.Wrap(context.DeadlineExceeded), http.StatusGatewayTimeout, http.StatusGatewayTimeout, errorx.CodeInternalErr.Msg()},
+		{errors.New("boom"), http.StatusInternalServerError, http.StatusInternalServerError, errorx.CodeInternalErr.Msg()},
+		{errorx.New("biz", http.StatusOK, "ok"), http.StatusInternalServerError, http.StatusOK, errorx.CodeInternalErr.Msg()},
+		{errorx.New("biz", 100001, "biz"), http.StatusInternalServerError, 100001, errorx.CodeInternalErr.Msg()},
+	} {
+		w := httptest.NewRecorder()
+		RespError(w, httptest.NewRequest(http.MethodGet, "/", nil), tc.err)
+		assert.Equal(t, tc.status, w.Code, tc.err.Error())
+		var res Response
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, tc.code, res.Code, tc.err.Error())
+		assert.Equal(t, tc.msg, res.Msg, tc.err.Error())
+	}
+	// The 503, 500s and 504, not the cancellation.
+	assert.Len(t, reported, 6)
+}
+
+func TestRespError_Localized(t *testing.T) {
+	defer SetRespConf(respConf)
+	catalog := i18n.NewCatalog(language.Chinese)
+	catalog.Parent = errorx.Catalog
+	catalog.Set(language.Chinese, 404001, "用户{name}不存在")
+	catalog.Set(language.English, 404001, "User {name} does not exist")
+	SetRespConf(RespConf{Catalog: catalog})
+
+	respond := func(ctx context.Context, acceptLanguage string, err error) Response {
+		r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		r.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		RespError(w, r, err)
+		var res Response
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res
+	}
+	notFound := func() error {
+		return errorx.New("user-Login", 404001, "用户不存在").
+			WithMetadata(errorx.Metadata{"name": "bob"}).Localize().Show()
+	}
+
+	ctx := context.Background()
+	assert.Equal(t, "User bob does not exist", respond(ctx, "en-US,zh;q=0.5", notFound()).Msg)
+	assert.Equal(t, "用户bob不存在", respond(ctx, "fr", notFound()).Msg)
+	assert.Equal(t, "用户bob不存在", respond(i18n.WithLanguages(ctx, language.Chinese), "en", notFound()).Msg)
+	assert.Equal(t, "The service is busy, please try again later", respond(ctx, "en", errors.New("boom")).Msg)
+	// Not localized, the message is shown as is.
+	assert.Equal(t, "no user", respond(ctx, "en", errorx.NotFound("no user").Show()).Msg)
+
+	w := httptest.NewRecorder()
+	RespSuccess(i18n.WithLanguages(ctx, language.English), w, nil)
+	var res Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "Success", res.Msg)
 }
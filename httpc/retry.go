@@ -0,0 +1,236 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+)
+
+// IdempotencyKeyHeader marks a non-idempotent request as safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryReason is why a failed request may be retried, empty if it may not.
+type RetryReason string
+
+const (
+	RetryNone      RetryReason = ""
+	RetryNetwork   RetryReason = "network"
+	RetryTimeout   RetryReason = "timeout"
+	RetryServer    RetryReason = "5xx"
+	RetryThrottled RetryReason = "429"
+	RetryTryAgain  RetryReason = "try_again"
+	RetryCondition RetryReason = "condition"
+)
+
+// RetryPolicy decides whether and when a failed request is sent again.
+type RetryPolicy struct {
+	MaxRetries int           // MaxRetries is the number of retries after the first attempt.
+	BaseDelay  time.Duration // BaseDelay is the delay before the first retry.
+	MaxDelay   time.Duration // MaxDelay caps the delay between two attempts.
+	MaxElapsed time.Duration // MaxElapsed caps the total time spent retrying, zero for no cap.
+
+	// Classify overrides ClassifyRetry.
+	Classify func(res *resty.Response, err error) RetryReason
+	// Conditions also retry a request when one of them returns true, like the
+	// retry conditions of resty.
+	Conditions []resty.RetryConditionFunc
+	// RetryNonIdempotent allows retrying POST and PATCH without an idempotency key.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is the policy of clients created by New.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: MaxRetryCount,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   2 * time.Second,
+	MaxElapsed: 30 * time.Second,
+}
+
+// ClassifyRetry tells whether a request ended with res and err is worth
+// retrying: network errors, timeouts, 5xx and 429 responses, and errs.TryAgain.
+func ClassifyRetry(res *resty.Response, err error) RetryReason {
+	if err != nil {
+		return classifyError(err)
+	}
+	if res == nil {
+		return RetryNone
+	}
+	switch code := res.StatusCode(); {
+	case code == http.StatusTooManyRequests:
+		return RetryThrottled
+	case code == http.StatusNotImplemented || code == http.StatusHTTPVersionNotSupported:
+		return RetryNone
+	case code >= http.StatusInternalServerError:
+		return RetryServer
+	}
+	return RetryNone
+}
+
+func classifyError(err error) RetryReason {
+	if errs.IsCancelled(err) || errs.Unwrap(err) == context.DeadlineExceeded {
+		// The caller gave up.
+		return RetryNone
+	}
+	if errs.TryAgain.Is(err) {
+		return RetryTryAgain
+	}
+	err = errs.Unwrap(err)
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		// The client timeout fired.
+		return RetryTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return RetryTimeout
+		}
+		return RetryNetwork
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return RetryNetwork
+	}
+	return RetryNone
+}
+
+// replayBody reads a body once, and gives every attempt its own reader of it.
+// resty reads io.Reader bodies in memory anyway to set GetBody.
+type replayBody struct {
+	once sync.Once
+	src  io.Reader
+	b    []byte
+	err  error
+}
+
+// replayedReader marks a body that can be sent again.
+type replayedReader struct {
+	io.Reader
+}
+
+func (rb *replayBody) reader() io.Reader {
+	rb.once.Do(func() {
+		rb.b, rb.err = io.ReadAll(rb.src)
+	})
+	if rb.err != nil {
+		return &replayedReader{Reader: errReader{rb.err}}
+	}
+	return &replayedReader{Reader: bytes.NewReader(rb.b)}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// isReplayable tells whether body gives the same content to every attempt.
+func isReplayable(body io.Reader) bool {
+	_, ok := body.(*replayedReader)
+	return ok
+}
+
+// replayable tells whether r can be sent again with the same body. An
+// io.Reader given to resty directly is drained by the first attempt, use
+// SetBody, SetFileReader or SetMultipart instead.
+func replayable(r *resty.Request) bool {
+	if b, ok := getStreamedBody(r.Context()); ok {
+		return b.replayable
+	}
+	if body, ok := r.Body.(io.Reader); ok {
+		return isReplayable(body)
+	}
+	return true
+}
+
+// IsIdempotent tells whether a request with method and header can be safely
+// sent more than once.
+func IsIdempotent(method string, header http.Header) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return header.Get(IdempotencyKeyHeader) != ""
+}
+
+// retryDelay returns how long to wait before sending r again, or false if
+// it should not be retried.
+func (p *RetryPolicy) retryDelay(r *resty.Request, attempt int, elapsed time.Duration, res *resty.Response, err error) (time.Duration, RetryReason, bool) {
+	if attempt >= p.MaxRetries {
+		return 0, RetryNone, false
+	}
+	if !p.RetryNonIdempotent && !IsIdempotent(r.Method, r.Header) {
+		return 0, RetryNone, false
+	}
+	if !replayable(r) {
+		return 0, RetryNone, false
+	}
+	classify := p.Classify
+	if classify == nil {
+		classify = ClassifyRetry
+	}
+	reason := classify(res, err)
+	for _, cond := range p.Conditions {
+		if reason != RetryNone {
+			break
+		}
+		if cond(res, err) {
+			reason = RetryCondition
+		}
+	}
+	if reason == RetryNone {
+		return 0, RetryNone, false
+	}
+
+	delay := timeutil.BackOff(attempt, p.BaseDelay, p.MaxDelay)
+	if wait, ok := throttled(res); ok && wait > delay {
+		delay = wait
+	}
+	if p.MaxElapsed > 0 && elapsed+delay > p.MaxElapsed {
+		return 0, reason, false
+	}
+	return delay, reason, true
+}
+
+// The retry settings of resty are mapped onto Client.Retry, as resty itself
+// doesn't retry the requests of a Client.
+
+// SetRetryCount sets Retry.MaxRetries.
+func (c *Client) SetRetryCount(count int) *Client {
+	c.Retry.MaxRetries = count
+	return c
+}
+
+// SetRetryWaitTime sets Retry.BaseDelay.
+func (c *Client) SetRetryWaitTime(waitTime time.Duration) *Client {
+	c.Retry.BaseDelay = waitTime
+	return c
+}
+
+// SetRetryMaxWaitTime sets Retry.MaxDelay.
+func (c *Client) SetRetryMaxWaitTime(maxWaitTime time.Duration) *Client {
+	c.Retry.MaxDelay = maxWaitTime
+	return c
+}
+
+// AddRetryCondition adds condition to Retry.Conditions.
+func (c *Client) AddRetryCondition(condition resty.RetryConditionFunc) *Client {
+	c.Retry.Conditions = append(c.Retry.Conditions, condition)
+	return c
+}
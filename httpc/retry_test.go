@@ -0,0 +1,134 @@
+package httpc
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyRetry(t *testing.T) {
+	assert.Equal(t, RetryTryAgain, ClassifyRetry(nil, errs.TryAgain.New("busy")))
+	assert.Equal(t, RetryNone, ClassifyRetry(nil, errs.InvalidArgument.New("bad")))
+	assert.True(t, IsIdempotent(http.MethodPut, http.Header{}))
+	assert.False(t, IsIdempotent(http.MethodPost, http.Header{}))
+	assert.True(t, IsIdempotent(http.MethodPost, http.Header{IdempotencyKeyHeader: []string{"k"}}))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	cl := New(SetRetryWaitTime(time.Millisecond))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		rfs      []RequestFunc
+		expected int32
+	}{
+		{"get 5xx", http.MethodGet, "/", nil, 1 + MaxRetryCount},
+		{"get 4xx", http.MethodGet, "/bad", nil, 1},
+		{"post 5xx", http.MethodPost, "/", nil, 1},
+		{"post 5xx with key", http.MethodPost, "/", []RequestFunc{SetIdempotencyKey("k")}, 1 + MaxRetryCount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			_, err := cl.Request(tt.method, server.URL+tt.path, tt.rfs...)
+			assert.Error(t, err)
+			assert.Equal(t, tt.expected, hits.Load())
+		})
+	}
+
+	hits.Store(0)
+	cl = New(SetRetryPolicy(RetryPolicy{MaxRetries: 10, BaseDelay: 40 * time.Millisecond, MaxDelay: 40 * time.Millisecond, MaxElapsed: 50 * time.Millisecond}))
+	_, err := cl.Get(server.URL)
+	assert.Error(t, err)
+	assert.Less(t, hits.Load(), int32(4))
+}
+
+func TestRetryPolicy_Body(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	cl := New(SetRetryWaitTime(time.Millisecond))
+
+	_, err := cl.Put(server.URL, SetBody(strings.NewReader("payload")))
+	assert.Error(t, err)
+	assert.Equal(t, []string{"payload", "payload", "payload", "payload"}, bodies)
+
+	// A reader given to resty directly can't be sent again.
+	bodies = nil
+	rd := strings.NewReader("payload")
+	_, err = cl.Put(server.URL, func(r *resty.Request) { r.SetBody(rd) })
+	assert.Error(t, err)
+	assert.Equal(t, []string{"payload"}, bodies)
+}
+
+func TestRetryPolicy_DoNotParseResponse(t *testing.T) {
+	var hits, conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("busy"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cl := New(SetRetryWaitTime(time.Millisecond))
+	res, err := cl.Get(server.URL, SetDoNotParseResponse())
+	assert.NoError(t, err)
+	defer res.RawBody().Close()
+	b, _ := io.ReadAll(res.RawBody())
+	assert.Equal(t, "ok", string(b))
+	assert.EqualValues(t, 2, hits.Load())
+	// The body of the discarded attempt was released, its connection reused.
+	assert.EqualValues(t, 1, conns.Load())
+}
+
+func TestClient_RestyRetrySettings(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cl := New()
+	cl.SetRetryCount(1).SetRetryWaitTime(time.Millisecond).AddRetryCondition(func(res *resty.Response, err error) bool {
+		return res != nil && res.StatusCode() == http.StatusBadRequest
+	})
+	_, err := cl.Get(server.URL)
+	assert.Error(t, err)
+	assert.EqualValues(t, 2, hits.Load())
+}
@@ -0,0 +1,322 @@
+package httpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+)
+
+// DefaultTransportName is the shared transport of clients created by New.
+const DefaultTransportName = "default"
+
+var (
+	metricTransportDialsTotal = mon.NewCounterVec(
+		"httpc", "transport_dials_total", "Number of connections dialed by shared transports.",
+		[]string{"transport", "result"})
+	metricTransportOpenConns = mon.NewGaugeVec(
+		"httpc", "transport_open_connections", "Number of connections opened by shared transports, idle or not.",
+		[]string{"transport"})
+	metricTransportInflight = mon.NewGaugeVec(
+		"httpc", "transport_inflight_requests", "Number of requests being sent by shared transports.",
+		[]string{"transport"})
+	metricTransportConnsTotal = mon.NewCounterVec(
+		"httpc", "transport_conns_total", "Number of connections obtained by requests, by whether they were reused.",
+		[]string{"transport", "reused"})
+)
+
+// TransportConf tunes a shared http.Transport.
+type TransportConf struct {
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int // MaxConnsPerHost caps connections to a host, zero for no cap.
+	IdleConnTimeout       time.Duration
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	DisableHTTP2          bool
+	TLSClientConfig       *tls.Config
+
+	// ProxyURL, a host:port, is used for hosts matching ProxyRulePattern, as
+	// httpie.ProxyByRule. Both are read from AGL_PROXY_URL and
+	// AGL_PROXY_RULE_PATTERN if ProxyURL is empty.
+	ProxyURL         string
+	ProxyRulePattern string
+}
+
+// DefaultTransportConf is the conf of transports not registered with RegisterTransport.
+var DefaultTransportConf = TransportConf{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 16,
+	IdleConnTimeout:     90 * time.Second,
+	DialTimeout:         30 * time.Second,
+	KeepAlive:           30 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+}
+
+var registry = struct {
+	sync.Mutex
+	transports map[string]*http.Transport
+	confs      map[string][]ClientFunc
+	clients    map[string]*Client
+}{
+	transports: map[string]*http.Transport{},
+	confs:      map[string][]ClientFunc{},
+	clients:    map[string]*Client{},
+}
+
+// RegisterTransport creates the shared transport of given name, used by
+// clients created afterwards with UseTransport(name). The transport it
+// replaces, if any, keeps serving the clients already using it.
+func RegisterTransport(name string, conf TransportConf) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.transports[name] = newTransport(name, conf)
+}
+
+// SharedTransport returns the transport registered as name, creating it with
+// DefaultTransportConf if needed.
+func SharedTransport(name string) *http.Transport {
+	registry.Lock()
+	defer registry.Unlock()
+	t, ok := registry.transports[name]
+	if !ok {
+		t = newTransport(name, DefaultTransportConf)
+		registry.transports[name] = t
+	}
+	return t
+}
+
+func isSharedTransport(rt http.RoundTripper) bool {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, t := range registry.transports {
+		if t == rt {
+			return true
+		}
+	}
+	return false
+}
+
+// Register configures the client returned by Named(name). It has no effect on
+// a client already returned by Named.
+func Register(name string, cfs ...ClientFunc) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.confs[name] = cfs
+}
+
+// Named returns the client registered as name, created on first use and then
+// shared. A name never registered gets a client with the default settings.
+// As they are shared by unrelated callers, named clients don't keep cookies.
+func Named(name string) *Client {
+	registry.Lock()
+	c, ok := registry.clients[name]
+	cfs := registry.confs[name]
+	registry.Unlock()
+	if ok {
+		return c
+	}
+
+	// New locks the registry for SharedTransport.
+	c = New(append([]ClientFunc{func(c *Client) { c.Client.SetCookieJar(nil) }}, cfs...)...)
+	registry.Lock()
+	defer registry.Unlock()
+	if existing, ok := registry.clients[name]; ok {
+		return existing
+	}
+	registry.clients[name] = c
+	return c
+}
+
+func newTransport(name string, conf TransportConf) *http.Transport {
+	proxy := httpie.ProxyFromEnv()
+	if conf.ProxyURL != "" {
+		proxy = httpie.ProxyByRule(conf.ProxyURL, conf.ProxyRulePattern)
+	}
+	dialer := &net.Dialer{
+		Timeout:   conf.DialTimeout,
+		KeepAlive: conf.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				metricTransportDialsTotal.With(mon.Labels{"transport": name, "result": "error"}).Inc()
+				return nil, err
+			}
+			metricTransportDialsTotal.With(mon.Labels{"transport": name, "result": "ok"}).Inc()
+			metricTransportOpenConns.With(mon.Labels{"transport": name}).Inc()
+			return &countedConn{Conn: conn, name: name}, nil
+		},
+		ForceAttemptHTTP2:     !conf.DisableHTTP2,
+		TLSClientConfig:       conf.TLSClientConfig,
+		MaxIdleConns:          conf.MaxIdleConns,
+		MaxIdleConnsPerHost:   conf.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       conf.MaxConnsPerHost,
+		IdleConnTimeout:       conf.IdleConnTimeout,
+		TLSHandshakeTimeout:   conf.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: conf.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// countedConn decrements the open connections gauge when closed.
+type countedConn struct {
+	net.Conn
+	name string
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		metricTransportOpenConns.With(mon.Labels{"transport": c.name}).Dec()
+	})
+	return c.Conn.Close()
+}
+
+// ownTransport gives c its own copy of its shared transport, before a
+// ClientFunc modifies it.
+func (c *Client) ownTransport() {
+	if t, ok := c.GetClient().Transport.(*http.Transport); ok && isSharedTransport(t) {
+		c.Client.SetTransport(t.Clone())
+	}
+}
+
+// wrapTransport decodes the responses of base, see httpie.DecodeTransport,
+// sends streamed bodies, and accounts for its requests if it is shared.
+func (c *Client) wrapTransport(base http.RoundTripper) {
+	rt := base
+	if c.transport != "" {
+		rt = &instrumentedTransport{name: c.transport, base: rt}
+	}
+	rt = &streamTransport{base: rt}
+	c.Client.SetTransport(&httpie.DecodeTransport{Base: rt, BodyConf: c.Body})
+}
+
+// baseTransport returns the transport below the wrappers of New.
+func (c *Client) baseTransport() http.RoundTripper {
+	rt := c.GetClient().Transport
+	if t, ok := rt.(*httpie.DecodeTransport); ok {
+		rt = t.Base
+	}
+	if t, ok := rt.(*streamTransport); ok {
+		rt = t.base
+	}
+	if t, ok := rt.(*instrumentedTransport); ok {
+		rt = t.base
+	}
+	return rt
+}
+
+// configureTransport runs fn with the base transport set on resty, so that
+// the transport methods of resty keep working once New wrapped it.
+func (c *Client) configureTransport(fn func()) {
+	_, wrapped := c.GetClient().Transport.(*httpie.DecodeTransport)
+	if wrapped {
+		c.Client.SetTransport(c.baseTransport())
+	}
+	fn()
+	if wrapped {
+		c.wrapTransport(c.GetClient().Transport)
+	}
+}
+
+// The transport methods of resty are overridden to configure the base
+// transport of the client, a copy of it if it is shared.
+
+// Transport returns the base transport of the client.
+func (c *Client) Transport() (*http.Transport, error) {
+	if t, ok := c.baseTransport().(*http.Transport); ok {
+		return t, nil
+	}
+	return nil, errors.New("httpc: current transport is not an *http.Transport instance")
+}
+
+// SetTransport replaces the base transport of the client, e.g. with a
+// cassette.Recorder in tests.
+func (c *Client) SetTransport(rt http.RoundTripper) *Client {
+	c.configureTransport(func() {
+		c.Client.SetTransport(rt)
+		c.transport = ""
+	})
+	return c
+}
+
+func (c *Client) SetProxy(proxyURL string) *Client {
+	c.configureTransport(func() {
+		c.ownTransport()
+		c.Client.SetProxy(proxyURL)
+	})
+	return c
+}
+
+func (c *Client) RemoveProxy() *Client {
+	c.configureTransport(func() {
+		c.ownTransport()
+		c.Client.RemoveProxy()
+	})
+	return c
+}
+
+func (c *Client) SetTLSClientConfig(config *tls.Config) *Client {
+	c.configureTransport(func() {
+		c.ownTransport()
+		c.Client.SetTLSClientConfig(config)
+	})
+	return c
+}
+
+func (c *Client) SetCertificates(certs ...tls.Certificate) *Client {
+	c.configureTransport(func() {
+		c.ownTransport()
+		c.Client.SetCertificates(certs...)
+	})
+	return c
+}
+
+func (c *Client) SetRootCertificate(pemFilePath string) *Client {
+	c.configureTransport(func() {
+		c.ownTransport()
+		c.Client.SetRootCertificate(pemFilePath)
+	})
+	return c
+}
+
+func (c *Client) SetRootCertificateFromString(pemContent string) *Client {
+	c.configureTransport(func() {
+		c.ownTransport()
+		c.Client.SetRootCertificateFromString(pemContent)
+	})
+	return c
+}
+
+// instrumentedTransport accounts for the requests and connection reuse of a
+// named transport.
+type instrumentedTransport struct {
+	name string
+	base http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inflight := metricTransportInflight.With(mon.Labels{"transport": t.name})
+	inflight.Inc()
+	defer inflight.Dec()
+
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metricTransportConnsTotal.With(mon.Labels{"transport": t.name, "reused": strconv.FormatBool(info.Reused)}).Inc()
+		},
+	})
+	return t.base.RoundTrip(req.WithContext(ctx))
+}
@@ -0,0 +1,89 @@
+package httpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leclerc04/go-tool/agl/base/mon"
+	"github.com/leclerc04/go-tool/agl/util/httpie"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedTransport_ReusesConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	RegisterTransport("test-reuse", DefaultTransportConf)
+	reused := metricTransportConnsTotal.With(mon.Labels{"transport": "test-reuse", "reused": "true"})
+	open := metricTransportOpenConns.With(mon.Labels{"transport": "test-reuse"})
+	reusedBefore, openBefore := testutil.ToFloat64(reused), testutil.ToFloat64(open)
+	for i := 0; i < 3; i++ {
+		_, err := New(UseTransport("test-reuse")).GetCtx(context.Background(), server.URL)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&conns))
+	assert.Equal(t, 2.0, testutil.ToFloat64(reused)-reusedBefore)
+	assert.Equal(t, 1.0, testutil.ToFloat64(open)-openBefore)
+
+	SharedTransport("test-reuse").CloseIdleConnections()
+	assert.Equal(t, openBefore, testutil.ToFloat64(open))
+}
+
+func TestSharedTransport_SetProxyCopies(t *testing.T) {
+	shared := SharedTransport(DefaultTransportName)
+	c := New(SetProxy("http://proxy.invalid:3128"))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	u, err := shared.Proxy(req)
+	assert.NoError(t, err)
+	assert.Nil(t, u)
+
+	own := c.GetClient().Transport.(*httpie.DecodeTransport).Base.(*streamTransport).base.(*instrumentedTransport).base.(*http.Transport)
+	assert.NotSame(t, shared, own)
+	u, err = own.Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.invalid:3128", u.Host)
+}
+
+func TestClient_SetProxyAfterNew(t *testing.T) {
+	shared := SharedTransport(DefaultTransportName)
+	c := New()
+	c.SetProxy("http://proxy.invalid:3128")
+
+	own, err := c.Transport()
+	assert.NoError(t, err)
+	assert.NotSame(t, shared, own)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	u, err := own.Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.invalid:3128", u.Host)
+	// Responses are still decoded and accounted for.
+	assert.Same(t, own, c.GetClient().Transport.(*httpie.DecodeTransport).Base.(*streamTransport).base.(*instrumentedTransport).base)
+
+	rt := http.RoundTripper(&http.Transport{})
+	c.SetTransport(rt)
+	assert.Same(t, rt, c.GetClient().Transport.(*httpie.DecodeTransport).Base.(*streamTransport).base)
+}
+
+func TestNamed(t *testing.T) {
+	Register("test-named", SetBaseURI("/api"), UseTransport("test-named"))
+	c := Named("test-named")
+	assert.Same(t, c, Named("test-named"))
+	assert.Equal(t, "/api", c.BaseURI)
+	assert.Equal(t, "test-named", c.transport)
+	assert.Nil(t, c.GetClient().Jar)
+	assert.Same(t, Named(DefaultTransportName), Named(DefaultTransportName))
+}
@@ -0,0 +1,226 @@
+package httpc
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leclerc04/go-tool/agl/util/errs"
+	"github.com/leclerc04/go-tool/agl/util/timeutil"
+	"github.com/leclerc04/go-tool/errorx"
+)
+
+const (
+	// TusVersion is the version of the tus resumable upload protocol spoken by UploadResumable.
+	TusVersion = "1.0.0"
+	// DefaultChunkSize is the size of the chunks sent by UploadResumable.
+	DefaultChunkSize = 8 << 20
+)
+
+// ResumableUploadConf describes an upload with the tus protocol, see https://tus.io/protocols/resumable-upload.
+type ResumableUploadConf struct {
+	// Endpoint creates the upload. It is not used if URL is set.
+	Endpoint string
+	// URL is the upload to continue, e.g. saved from a previous call that failed.
+	// It is set once the upload is created.
+	URL string
+
+	// Reader and Size give the content, or Path if Reader is nil.
+	Reader io.ReaderAt
+	Size   int64
+	Path   string
+
+	// Metadata is sent when creating the upload, e.g. {"filename": "scan.zip"}.
+	Metadata map[string]string
+	// ChunkSize is the size of each PATCH request, DefaultChunkSize if zero.
+	// A chunk is held in memory while it is sent.
+	ChunkSize int64
+	// Checksum sends the sha1 of each chunk in Upload-Checksum, for servers
+	// supporting the checksum extension.
+	Checksum bool
+
+	// MaxResume limits how many times the upload continues after a failed
+	// chunk. Zero means DefaultMaxResume, negative disables resuming.
+	MaxResume int
+	// Progress, if set, is called after each chunk is acknowledged.
+	Progress func(sent, total int64)
+}
+
+// UploadResumable uploads the content with the tus protocol: it creates the
+// upload unless conf.URL is set, then sends the content in chunks. After a
+// failed chunk, it asks the server for the offset it reached and continues
+// from there.
+func UploadResumable(ctx context.Context, c *Client, conf *ResumableUploadConf, rfs ...RequestFunc) error {
+	src, size := conf.Reader, conf.Size
+	if src == nil {
+		f, err := os.Open(conf.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		st, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		src, size = f, st.Size()
+	}
+	maxResume := conf.MaxResume
+	if maxResume == 0 {
+		maxResume = DefaultMaxResume
+	}
+	chunkSize := conf.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if size < chunkSize {
+		chunkSize = size
+	}
+	rfs = append([]RequestFunc{SetHeader("Tus-Resumable", TusVersion), SetHeader("Accept", "*/*")}, rfs...)
+
+	u := &uploader{c: c, conf: conf, rfs: rfs}
+	var offset int64
+	var err error
+	if conf.URL == "" {
+		if err = u.create(ctx, size); err != nil {
+			return err
+		}
+	} else if offset, err = u.offset(ctx); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	for attempt := 0; offset < size; {
+		n := int64(len(buf))
+		if size-offset < n {
+			n = size - offset
+		}
+		if _, err = src.ReadAt(buf[:n], offset); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		var next int64
+		next, err = u.patch(ctx, offset, buf[:n])
+		if err == nil {
+			offset = next
+			if conf.Progress != nil {
+				conf.Progress(offset, size)
+			}
+			continue
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errs.Wrap(ctxErr)
+		}
+		if attempt >= maxResume || !resumableUpload(err) {
+			return err
+		}
+		if err = timeutil.Sleep(ctx, timeutil.BackOff(attempt, 500*time.Millisecond, 10*time.Second)); err != nil {
+			return errs.Wrap(err)
+		}
+		attempt++
+		// The server may have stored part of the chunk.
+		if offset, err = u.offset(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type uploader struct {
+	c    *Client
+	conf *ResumableUploadConf
+	rfs  []RequestFunc
+}
+
+func (u *uploader) create(ctx context.Context, size int64) error {
+	rfs := append(u.rfs[:len(u.rfs):len(u.rfs)], SetHeader("Upload-Length", strconv.FormatInt(size, 10)))
+	if len(u.conf.Metadata) > 0 {
+		rfs = append(rfs, SetHeader("Upload-Metadata", encodeTusMetadata(u.conf.Metadata)))
+	}
+	res, err := u.c.PostCtx(ctx, u.conf.Endpoint, rfs...)
+	if err != nil {
+		return err
+	}
+	loc, err := url.Parse(res.Header().Get("Location"))
+	if err != nil || loc.String() == "" {
+		return errorx.New("http-Upload", http.StatusBadGateway, "upload created without location").
+			WithMetadata(errorx.Metadata{"url": u.conf.Endpoint, "status": res.StatusCode()})
+	}
+	if raw := res.RawResponse; raw != nil && raw.Request != nil {
+		loc = raw.Request.URL.ResolveReference(loc)
+	}
+	u.conf.URL = loc.String()
+	return nil
+}
+
+// offset asks the server how much of the upload it has received.
+func (u *uploader) offset(ctx context.Context) (int64, error) {
+	res, err := u.c.HeadCtx(ctx, u.conf.URL, u.rfs...)
+	if err != nil {
+		return 0, err
+	}
+	return u.parseOffset(res.Header().Get("Upload-Offset"))
+}
+
+func (u *uploader) patch(ctx context.Context, offset int64, chunk []byte) (int64, error) {
+	rfs := append(u.rfs[:len(u.rfs):len(u.rfs)],
+		SetHeader("Content-Type", "application/offset+octet-stream"),
+		SetHeader("Upload-Offset", strconv.FormatInt(offset, 10)),
+		SetBody(chunk))
+	if u.conf.Checksum {
+		sum := sha1.Sum(chunk)
+		rfs = append(rfs, SetHeader("Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:])))
+	}
+	res, err := u.c.PatchCtx(ctx, u.conf.URL, rfs...)
+	if err != nil {
+		return 0, err
+	}
+	return u.parseOffset(res.Header().Get("Upload-Offset"))
+}
+
+func (u *uploader) parseOffset(v string) (int64, error) {
+	offset, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errorx.New("http-Upload", http.StatusBadGateway, "invalid Upload-Offset").
+			WithMetadata(errorx.Metadata{"url": u.conf.URL, "offset": v})
+	}
+	return offset, nil
+}
+
+// resumableUpload tells whether a failed chunk is worth asking for the offset.
+// 409 means the offset we sent doesn't match the server's one.
+func resumableUpload(err error) bool {
+	if errs.IsCancelled(err) {
+		return false
+	}
+	var e *errorx.Error
+	if errors.As(err, &e) {
+		return e.Code >= http.StatusInternalServerError || e.Code == http.StatusTooManyRequests ||
+			e.Code == http.StatusConflict
+	}
+	return true
+}
+
+// encodeTusMetadata encodes "key base64(value)" pairs separated by commas.
+func encodeTusMetadata(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + " " + base64.StdEncoding.EncodeToString([]byte(m[k]))
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,107 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tusServer is a minimal tus stand-in keeping a single upload in memory.
+type tusServer struct {
+	mu       sync.Mutex
+	length   int64
+	data     []byte
+	metadata string
+	patches  int
+	// failAt makes the nth PATCH store half of the chunk then fail.
+	failAt int
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("Tus-Resumable", TusVersion)
+	switch r.Method {
+	case http.MethodPost:
+		s.length, _ = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		s.metadata = r.Header.Get("Upload-Metadata")
+		w.Header().Set("Location", "/files/1")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.Header().Set("Upload-Length", strconv.FormatInt(s.length, 10))
+	case http.MethodPatch:
+		s.patches++
+		if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(s.data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		if s.patches == s.failAt {
+			s.data = append(s.data, b[:len(b)/2]...)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.data = append(s.data, b...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestUploadResumable(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	tus := &tusServer{failAt: 2}
+	server := httptest.NewServer(tus)
+	defer server.Close()
+
+	var sent int64
+	conf := &ResumableUploadConf{
+		Endpoint:  server.URL + "/files",
+		Reader:    bytes.NewReader(content),
+		Size:      int64(len(content)),
+		ChunkSize: 4000,
+		Metadata:  map[string]string{"filename": "digits.txt"},
+		Checksum:  true,
+		Progress:  func(s, total int64) { sent = s },
+	}
+	err := UploadResumable(context.Background(), New(), conf)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/files/1", conf.URL)
+	assert.Equal(t, "filename "+base64.StdEncoding.EncodeToString([]byte("digits.txt")), tus.metadata)
+	assert.Equal(t, content, tus.data)
+	assert.Equal(t, int64(len(content)), sent)
+	// 4000, 4000 failing halfway, then the 4000 left from offset 6000.
+	assert.Equal(t, 3, tus.patches)
+}
+
+func TestUploadResumable_Continue(t *testing.T) {
+	content := []byte("hello, world")
+	tus := &tusServer{length: int64(len(content)), data: []byte("hello")}
+	server := httptest.NewServer(tus)
+	defer server.Close()
+
+	err := UploadResumable(context.Background(), New(), &ResumableUploadConf{
+		URL:    server.URL + "/files/1",
+		Reader: bytes.NewReader(content),
+		Size:   int64(len(content)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, content, tus.data)
+	assert.Equal(t, 1, tus.patches)
+}